@@ -0,0 +1,201 @@
+package efft
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ypsu/efftesting/efft/internal"
+	"golang.org/x/tools/txtar"
+)
+
+// CassetteTransport is an http.RoundTripper that replays the HTTP exchanges recorded in a cassette file.
+// See Cassette.
+type CassetteTransport struct {
+	// Transport sends the requests in the EFFUP=1 mode.
+	// Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+
+	tt        *testing.T
+	path      string
+	mu        sync.Mutex
+	exchanges []cassetteExchange
+	unmatched int
+}
+
+type cassetteExchange struct {
+	request, response string
+	used              bool
+}
+
+// Cassette returns a transport that replays the HTTP exchanges recorded in the cassette file at path.
+// Use it as the http.Client's Transport in the code under test.
+// Each request is answered with the first unused recorded response whose request has the same method, URI and body.
+// The unmatched requests and the unused exchanges fail the test.
+//
+// With EFFUP=1 the transport sends the requests to their real destination instead and records the exchanges.
+// The cassette file is rewritten at the end of the test if it changed.
+// The cassette is a txtar archive with request and response sections in the HTTP/1.1 wire format.
+// The VolatileHeaders are removed from the responses in both modes, the rest of the responses including the bodies are replayed as recorded.
+func Cassette(tt *testing.T, path string) *CassetteTransport {
+	tt.Helper()
+	c := &CassetteTransport{tt: tt, path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		tt.Fatalf("efft.ReadCassette: %v", err)
+	}
+	if !updatemode {
+		files := txtar.Parse(data).Files
+		for i := 0; i+1 < len(files); i += 2 {
+			c.exchanges = append(c.exchanges, cassetteExchange{
+				request:  strings.TrimSuffix(string(files[i].Data), "\n"),
+				response: strings.TrimSuffix(string(files[i+1].Data), "\n"),
+			})
+		}
+	}
+	tt.Cleanup(func() {
+		tt.Helper()
+		if updatemode {
+			c.update(string(data))
+			return
+		}
+		unused := 0
+		for _, e := range c.exchanges {
+			if !e.used {
+				unused++
+			}
+		}
+		if unused > 0 {
+			tt.Errorf("efft.UnusedExchanges count=%d", unused)
+		}
+		if unused > 0 || c.unmatched > 0 {
+			tt.Errorf("efft.WrongCassette: run with EFFUP=1 envvar to fix it")
+		}
+	})
+	return c
+}
+
+// RoundTrip implements http.RoundTripper.
+func (c *CassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	request, sent := cassetteRequest(req)
+	if updatemode {
+		resp, err := cmp.Or(c.Transport, http.DefaultTransport).RoundTrip(sent)
+		if err != nil {
+			return nil, err
+		}
+		response := cassetteResponse(resp)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.exchanges = append(c.exchanges, cassetteExchange{request: request, response: response})
+		return resp, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, e := range c.exchanges {
+		if !e.used && e.request == request {
+			c.exchanges[i].used = true
+			return parseCassetteResponse(e.response, req)
+		}
+	}
+	c.unmatched++
+	c.tt.Errorf("efft.UnmatchedRequest file=%s:\n%s", c.path, request)
+	return nil, fmt.Errorf("efft.UnmatchedRequest %s %s", req.Method, req.URL)
+}
+
+// update writes the recorded exchanges into the cassette file if they differ from its old content.
+func (c *CassetteTransport) update(old string) {
+	c.tt.Helper()
+	archive := &txtar.Archive{Comment: []byte("# HTTP cassette, rerecord with EFFUP=1.\n")}
+	for _, e := range c.exchanges {
+		archive.Files = append(archive.Files,
+			txtar.File{Name: "request", Data: []byte(e.request + "\n")},
+			txtar.File{Name: "response", Data: []byte(e.response + "\n")})
+	}
+	recorded := string(txtar.Format(archive))
+	if recorded == old {
+		return
+	}
+	d := Diff(old, recorded)
+	c.tt.Errorf("efft.CassetteDiff file=%s -expectation +runtime:\n%s", c.path, d)
+	if askmode {
+		if err := openReviewer(); err != nil {
+			c.tt.Fatalf("efft.OpenTerminal: %v", err)
+		}
+		if !reviewer.Review(internal.Location{Fname: c.path, Line: 1}, "", d) {
+			return
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		c.tt.Fatalf("efft.WriteCassette: %v", err)
+	}
+	if err := os.WriteFile(c.path, []byte(recorded), 0644); err != nil {
+		c.tt.Fatalf("efft.WriteCassette: %v", err)
+	}
+	c.tt.Logf("efft.ExpectationsUpdatedSuccessfully file=%s", c.path)
+}
+
+// cassetteRequest renders the matched parts of the request: the method, the URI and the body.
+// The host is omitted so that the recordings work with test servers on random ports too.
+// It consumes the body so it also returns the request to send: a clone of r with the body restored.
+// r itself stays unmodified as the http.RoundTripper contract requires.
+func cassetteRequest(r *http.Request) (string, *http.Request) {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "%s %s HTTP/1.1\n", cmp.Or(r.Method, http.MethodGet), r.URL.RequestURI())
+	if r.Body != nil && r.Body != http.NoBody {
+		body := r.Body
+		r = r.Clone(r.Context())
+		r.Body = writeBody(sb, body, false)
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), r
+}
+
+// cassetteResponse renders the response in the wire format without reformatting the body so that it can be replayed exactly.
+// It removes the VolatileHeaders from r so that the recorded and the replayed responses are the same.
+func cassetteResponse(r *http.Response) string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "HTTP/1.1 %d %s\n", r.StatusCode, http.StatusText(r.StatusCode))
+	for _, key := range VolatileHeaders {
+		r.Header.Del(key)
+	}
+	writeHeader(sb, r.Header)
+	headLen := sb.Len()
+	r.Body = writeBody(sb, r.Body, false)
+	if sb.Len() == headLen {
+		return strings.TrimSuffix(sb.String(), "\n")
+	}
+	return sb.String()
+}
+
+// parseCassetteResponse parses a response rendered by cassetteResponse.
+func parseCassetteResponse(s string, req *http.Request) (*http.Response, error) {
+	head, body, _ := strings.Cut(s, "\n\n")
+	lines := strings.Split(head, "\n")
+	var code int
+	if _, err := fmt.Sscanf(lines[0], "HTTP/1.1 %d", &code); err != nil {
+		return nil, fmt.Errorf("efft.ParseStatusLine line=%q: %v", lines[0], err)
+	}
+	header := http.Header{}
+	for _, line := range lines[1:] {
+		key, value, _ := strings.Cut(line, ": ")
+		header.Add(key, value)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
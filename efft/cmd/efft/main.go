@@ -0,0 +1,140 @@
+// Command efft contains helper subcommands for the efft testing package.
+//
+// Usage:
+//
+//	efft review [go test args]
+//	efft apply report.json...
+//	efft migrate [-noupdate] [dirs...]
+//
+// review runs `go test` with EFFUP=ask so that the changed expectations can be reviewed one by one on the terminal.
+// The packages are tested one at a time and without caching so that the questions don't interleave.
+//
+// apply updates the failing expectations recorded in EFFREPORT files without rerunning the tests.
+//
+// migrate rewrites the testify assertions and the `if got != want { t.Errorf(...) }` checks of the test files in the given directories into efft calls.
+// Then it runs the tests with EFFUP=1 to fill in the expectations unless -noupdate is given.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ypsu/efftesting/efft/internal"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: efft review [go test args]")
+	fmt.Fprintln(os.Stderr, "       efft apply report.json...")
+	fmt.Fprintln(os.Stderr, "       efft migrate [-noupdate] [dirs...]")
+	os.Exit(2)
+}
+
+func review(args []string) error {
+	cmd := exec.Command("go", append([]string{"test", "-p=1", "-count=1"}, args...)...)
+	cmd.Env = append(os.Environ(), "EFFUP=ask")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("efft.GoTest: %v", err)
+	}
+	return nil
+}
+
+func apply(reports []string) error {
+	replacer := internal.Replacer{Replacements: map[internal.Location]string{}}
+	for _, report := range reports {
+		f, err := os.Open(report)
+		if err != nil {
+			return fmt.Errorf("efft.OpenReport: %v", err)
+		}
+		replacements, err := internal.ReadReport(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("efft.ReadReport file=%s: %v", report, err)
+		}
+		for loc, newstr := range replacements {
+			replacer.Replacements[loc] = newstr
+		}
+	}
+	return replacer.ApplyAll()
+}
+
+func migrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	noupdate := flags.Bool("noupdate", false, "don't run the tests with EFFUP=1 after the rewrite")
+	flags.Parse(args)
+	dirs := flags.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	var pkgs []string
+	for _, dir := range dirs {
+		fnames, err := filepath.Glob(filepath.Join(dir, "*_test.go"))
+		if err != nil {
+			return fmt.Errorf("efft.ListFiles: %v", err)
+		}
+		migrated := false
+		for _, fname := range fnames {
+			src, err := os.ReadFile(fname)
+			if err != nil {
+				return fmt.Errorf("efft.ReadFile: %v", err)
+			}
+			newsrc, cnt, err := internal.Migrate(fname, src)
+			if err != nil {
+				return fmt.Errorf("efft.Migrate file=%s: %v", fname, err)
+			}
+			if cnt == 0 {
+				continue
+			}
+			if err := os.WriteFile(fname, newsrc, 0644); err != nil {
+				return fmt.Errorf("efft.WriteBack: %v", err)
+			}
+			fmt.Printf("efft.Migrated file=%s checks=%d\n", fname, cnt)
+			migrated = true
+		}
+		if migrated && !filepath.IsAbs(dir) && !strings.HasPrefix(dir, ".") {
+			dir = "./" + dir
+		}
+		if migrated {
+			pkgs = append(pkgs, dir)
+		}
+	}
+	if *noupdate || len(pkgs) == 0 {
+		return nil
+	}
+
+	// The first run fails due to the incomplete expectations so only the second run's result matters.
+	cmd := exec.Command("go", append([]string{"test", "-count=1"}, pkgs...)...)
+	cmd.Env = append(os.Environ(), "EFFUP=1")
+	cmd.Run()
+	cmd = exec.Command("go", append([]string{"test", "-count=1"}, pkgs...)...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("efft.GoTest: %v", err)
+	}
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "review":
+		err = review(os.Args[2:])
+	case "apply":
+		err = apply(os.Args[2:])
+	case "migrate":
+		err = migrate(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
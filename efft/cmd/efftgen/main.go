@@ -0,0 +1,71 @@
+// Command efftgen generates recording fakes for interfaces.
+//
+// Usage:
+//
+//	//go:generate efftgen -type=Store[,OtherInterface...] [-output=file.go]
+//
+// For the Store interface it generates FakeStore into store_fake_test.go of the current package by default.
+// The interfaces must be defined in the package's non-test files.
+// FakeStore has a <Method>Func field for each method, e.g. the GetFunc field implements the Get method.
+// The methods without such a function return zero values.
+// Each call is recorded with its arguments and results, check them with `efft.Effect(fake.Calls())`.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ypsu/efftesting/efft/internal"
+	"golang.org/x/tools/go/packages"
+)
+
+func run() error {
+	typeFlag := flag.String("type", "", "comma separated list of the interfaces to fake")
+	outputFlag := flag.String("output", "", "the output file, defaults to <first type>_fake_test.go")
+	flag.Parse()
+	if *typeFlag == "" || flag.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: efftgen -type=Interface[,Interface...] [-output=file.go] [package]")
+		os.Exit(2)
+	}
+	names := strings.Split(*typeFlag, ",")
+	pattern := "."
+	if flag.NArg() == 1 {
+		pattern = flag.Arg(0)
+	}
+
+	// Load the package without its test files because a stale fake in them might not compile.
+	// Type check everything from source so that the export data format of the go toolchain doesn't matter.
+	mode := packages.NeedName | packages.NeedTypes | packages.NeedSyntax | packages.NeedImports | packages.NeedDeps
+	pkgs, err := packages.Load(&packages.Config{Mode: mode}, pattern)
+	if err != nil {
+		return fmt.Errorf("efft.LoadPackage: %v", err)
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		return fmt.Errorf("efft.LoadPackage: the package has errors")
+	}
+	if len(pkgs) != 1 {
+		return fmt.Errorf("efft.LoadPackage: %d packages match %q, want 1", len(pkgs), pattern)
+	}
+
+	src, err := internal.GenerateFakes(pkgs[0].Types, names)
+	if err != nil {
+		return err
+	}
+	output := *outputFlag
+	if output == "" {
+		output = strings.ToLower(names[0]) + "_fake_test.go"
+	}
+	if err := os.WriteFile(output, src, 0644); err != nil {
+		return fmt.Errorf("efft.WriteFake: %v", err)
+	}
+	return nil
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,16 @@
+// Command efftvet reports efft misuses, see the efftvet package for the details.
+//
+// Usage:
+//
+//	go install github.com/ypsu/efftesting/efft/cmd/efftvet
+//	go vet -vettool=$(which efftvet) ./...
+package main
+
+import (
+	"github.com/ypsu/efftesting/efft/efftvet"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+func main() {
+	unitchecker.Main(efftvet.Analyzer)
+}
@@ -0,0 +1,107 @@
+package efft
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ypsu/efftesting/efft/internal"
+)
+
+// RunFile runs the data-driven test files matching glob, each in its own subtest.
+// Each file consists of blocks like this:
+//
+//	# Comment.
+//	upper
+//	hello world
+//	----
+//	HELLO WORLD
+//
+// The first line is the command and then comes the input until the "----" separator.
+// The expected output follows until the next empty line.
+// If the output contains empty lines then it's wrapped into an extra pair of separators:
+//
+//	split
+//	a,,b
+//	----
+//	----
+//	a
+//
+//	b
+//	----
+//	----
+//
+// RunFile calls fn with each command and input and compares the result with the expected output.
+// A trailing newline of the result is ignored.
+// EFFUP=1 rewrites the output sections in the data files, EFFUP=ask asks about each change first.
+// So for a new test case it's enough to write its command, input and an empty output section.
+func RunFile(tt *testing.T, glob string, fn func(cmd, input string) string) {
+	tt.Helper()
+	fnames, err := filepath.Glob(glob)
+	if err != nil {
+		tt.Fatalf("efft.BadGlob: %v", err)
+	}
+	if len(fnames) == 0 {
+		tt.Fatalf("efft.NoDataFiles glob=%q", glob)
+	}
+	for _, fname := range fnames {
+		tt.Run(filepath.Base(fname), func(tt *testing.T) { runDataFile(tt, fname, fn) })
+	}
+}
+
+func runDataFile(tt *testing.T, fname string, fn func(cmd, input string) string) {
+	tt.Helper()
+	src, err := os.ReadFile(fname)
+	if err != nil {
+		tt.Fatalf("efft.ReadDataFile: %v", err)
+	}
+	updated, ok := checkData(tt, fname, string(src), fn)
+	if !ok {
+		return
+	}
+	if err := os.WriteFile(fname, []byte(updated), 0644); err != nil {
+		tt.Fatalf("efft.WriteDataFile: %v", err)
+	}
+	tt.Logf("efft.ExpectationsUpdatedSuccessfully file=%s", fname)
+}
+
+// checkData runs fn on the blocks of the data-driven src and reports the mismatches.
+// Returns the src with the updated output sections and true if some outputs need to be rewritten.
+func checkData(tt *testing.T, fname, src string, fn func(cmd, input string) string) (string, bool) {
+	tt.Helper()
+	blocks, err := internal.ParseDataFile(fname, src)
+	if err != nil {
+		tt.Fatal(err)
+	}
+	wrong, updated := 0, 0
+	for i, b := range blocks {
+		got := strings.TrimSuffix(fn(b.Cmd, b.Input), "\n")
+		if got == b.Output {
+			continue
+		}
+		wrong++
+		d := Diff(b.Output, got)
+		tt.Errorf("efft.DataDiff %s:%d -expectation +runtime:\n%s", fname, b.Line, d)
+		if !updatemode {
+			continue
+		}
+		if askmode {
+			if err := openReviewer(); err != nil {
+				tt.Fatalf("efft.OpenTerminal: %v", err)
+			}
+			if !reviewer.Review(internal.Location{Fname: fname, Line: b.Line}, "", d) {
+				continue
+			}
+		}
+		blocks[i].Output = got
+		updated++
+	}
+	if wrong > 0 && !updatemode {
+		tt.Errorf("efft.WrongExpectations: run with EFFUP=1 envvar to fix them")
+	}
+	if updated == 0 {
+		return "", false
+	}
+	return internal.UpdateDataFile(src, blocks), true
+}
@@ -0,0 +1,130 @@
+package efft
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// EffectDir sets up an expectation for the directory tree at path.
+// See EffectFS for the format.
+func EffectDir(path string) result { //revive:disable-line:unexported-return
+	checkT()
+	t.Helper()
+	got := scrub(stringifyFS(os.DirFS(path)))
+	return track(result{got, defaultReplacer.Replace(got), false, diffFS, false})
+}
+
+// EffectFS sets up an expectation for the files in fsys in a txtar-like format.
+// Each file and directory has a `-- path (mode) --` header, sorted by path.
+// The text files' content follows their header with a newline added if it was missing.
+// Binary files are represented by their size and SHA-256 hash.
+// The diff of a mismatch is shown per file.
+func EffectFS(fsys fs.FS) result { //revive:disable-line:unexported-return
+	checkT()
+	t.Helper()
+	got := scrub(stringifyFS(fsys))
+	return track(result{got, defaultReplacer.Replace(got), false, diffFS, false})
+}
+
+// isText reports whether the data is valid UTF-8 without unusual control characters.
+// Such data is rendered as is, the rest is rendered in a binary form.
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !strings.ContainsFunc(string(data), func(r rune) bool {
+		return unicode.IsControl(r) && r != '\n' && r != '\t' && r != '\r'
+	})
+}
+
+func stringifyFS(fsys fs.FS) string {
+	sb := &strings.Builder{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			fmt.Fprintf(sb, "-- %s/ (%v) --\n", name, info.Mode())
+			return nil
+		}
+		fmt.Fprintf(sb, "-- %s (%v) --\n", name, info.Mode())
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		switch {
+		case len(data) == 0:
+		case isText(data):
+			sb.Write(data)
+			if data[len(data)-1] != '\n' {
+				sb.WriteByte('\n')
+			}
+		default:
+			fmt.Fprintf(sb, "binary size=%d sha256=%x\n", len(data), sha256.Sum256(data))
+		}
+		return nil
+	})
+	if err != nil {
+		return "efft.WalkDir: " + err.Error()
+	}
+	return sb.String()
+}
+
+// splitFS splits a stringified filesystem into the per-file sections keyed by the path.
+func splitFS(s string) (paths []string, sections map[string]string) {
+	sections = map[string]string{}
+	path := ""
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if strings.HasPrefix(line, "-- ") && strings.HasSuffix(line, " --\n") {
+			header := strings.TrimSuffix(strings.TrimPrefix(line, "-- "), " --\n")
+			if i := strings.LastIndex(header, " ("); i != -1 {
+				header = header[:i]
+			}
+			path = header
+			paths = append(paths, path)
+		}
+		sections[path] += line
+	}
+	return paths, sections
+}
+
+// diffFS diffs the stringified filesystems file by file.
+// Each file's diff has a `=== path` header, the diff lines start with a space, + or - so they can't be mistaken for headers.
+func diffFS(want, got string) string {
+	wantPaths, wantSections := splitFS(want)
+	gotPaths, gotSections := splitFS(got)
+	paths := slices.Compact(slices.Sorted(slices.Values(append(wantPaths, gotPaths...))))
+	if _, found := wantSections[""]; found {
+		paths = append([]string{""}, paths...)
+	} else if _, found := gotSections[""]; found {
+		paths = append([]string{""}, paths...)
+	}
+	sb := &strings.Builder{}
+	for _, path := range paths {
+		w, wok := wantSections[path]
+		g, gok := gotSections[path]
+		switch {
+		case w == g:
+		case !wok:
+			fmt.Fprintf(sb, "=== %s (added)\n+%s\n", path, strings.ReplaceAll(strings.TrimSuffix(g, "\n"), "\n", "\n+"))
+		case !gok:
+			fmt.Fprintf(sb, "=== %s (removed)\n-%s\n", path, strings.ReplaceAll(strings.TrimSuffix(w, "\n"), "\n", "\n-"))
+		default:
+			fmt.Fprintf(sb, "=== %s\n%s", path, Diff(w, g))
+		}
+	}
+	return sb.String()
+}
@@ -31,19 +31,37 @@
 //	}
 //
 // Note that if the function's last arg is a nil error or true boolean then it's automatically omitted.
+//
+// Use `EFFUP=ask go test ./...` to review the changes one by one on the terminal before they are applied.
+//
+// Set EFFREPORT=/some/path.json to append a JSON record about each effect's outcome to that file, one per line.
+// Use an absolute path because the tests run in their package's directory.
+// Then `efft apply /some/path.json` can apply the changes later without rerunning the tests.
+//
+// Set EFFSTALE=1 to list the efft.Effect calls in the package's test files that never ran, e.g. due to build tags or skipped tests.
+// EFFSTALE=fail fails the test binary too if there are such calls.
+// This needs `func TestMain(m *testing.M) { efft.Main(m) }` in the package.
 package efft
 
 import (
+	"bufio"
+	"cmp"
+	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
+	"unicode"
 
 	"github.com/ypsu/efftesting/efft/internal"
+	"golang.org/x/text/unicode/norm"
 )
 
 // Note to include in the error message when there's a diff between the effect's got and wanted value.
@@ -54,15 +72,32 @@ var Note string
 // This string must always be a string constant passed into the function due to the auto-rewrite feature.
 type expectationString string
 
+// mismatch contains the details of a wrong expectation for the review.
+type mismatch struct {
+	note, diff string
+}
+
 var (
 	t               *testing.T
 	updatemode      bool
+	askmode         bool
 	rewriterPipe    io.Writer
+	reviewer        *internal.Reviewer
+	mismatches      map[internal.Location]mismatch
+	pending         map[internal.Location]result
+	reportPath      string
+	reportFile      *os.File
+	stalemode       string
 	defaultReplacer internal.Replacer
 )
 
 func init() {
-	updatemode = os.Getenv("EFFUP") == "1"
+	effup := os.Getenv("EFFUP")
+	updatemode, askmode = effup == "1" || effup == "ask", effup == "ask"
+	reportPath = os.Getenv("EFFREPORT")
+	if stalemode = os.Getenv("EFFSTALE"); stalemode != "" {
+		defaultReplacer.Hits = map[internal.Location]bool{}
+	}
 	if os.Getenv("EFFTESTING_REWRITE") != "1" {
 		return
 	}
@@ -97,22 +132,33 @@ func Init(tt *testing.T) {
 	}
 	t = tt
 	Note = ""
+	runMainCalls = 0
+	imageCalls = 0
+	scrubber, scrubPairs = nil, nil
 	t.Cleanup(func() { t = nil })
 	defaultReplacer.Incomplete = map[internal.Location]bool{}
 	defaultReplacer.Replacements = map[internal.Location]string{}
+	mismatches = map[internal.Location]mismatch{}
+	pending = map[internal.Location]result{}
 	t.Cleanup(func() {
 		t.Helper()
+		for _, loc := range slices.SortedFunc(maps.Keys(pending), compareLocations) {
+			report(pending[loc], "incomplete", "", mismatches[loc].note)
+		}
 		incomplete, replacements := defaultReplacer.Incomplete, defaultReplacer.Replacements
-		if !updatemode && len(incomplete) > 0 {
-			t.Errorf("efft.IncompleteExpectations: run with EFFUP=1 envvar to complete them")
-		} else if len(incomplete) > 0 {
-			t.Errorf("efft.IncompleteExpectations: will update them at end")
+		accepted := replacements
+		if askmode && len(replacements) > 0 {
+			accepted = review(replacements)
 		}
-		if !updatemode && len(replacements) > len(incomplete) {
-			t.Errorf("efft.WrongExpectations: run with EFFUP=1 envvar to fix them")
-		} else if len(replacements) > len(incomplete) {
-			t.Errorf("efft.WrongExpectations: will update them at end")
+		acceptedIncomplete := 0
+		for loc := range accepted {
+			if incomplete[loc] {
+				acceptedIncomplete++
+			}
 		}
+		reportOutcome("IncompleteExpectations", "complete", len(incomplete), acceptedIncomplete)
+		reportOutcome("WrongExpectations", "fix", len(replacements)-len(incomplete), len(accepted)-acceptedIncomplete)
+		replacements = accepted
 		if !updatemode || len(replacements) == 0 {
 			return
 		}
@@ -135,6 +181,151 @@ func Init(tt *testing.T) {
 	})
 }
 
+// Main runs the tests and reports the stale effects if EFFSTALE is set.
+// Call it from TestMain:
+//
+//	func TestMain(m *testing.M) { efft.Main(m) }
+func Main(m *testing.M) {
+	code := m.Run()
+	if stalemode == "" {
+		os.Exit(code)
+	}
+	stale, err := staleEffects()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "efft.ListEffects: %v\n", err)
+		os.Exit(1)
+	}
+	for _, loc := range stale {
+		fmt.Fprintf(os.Stderr, "efft.StaleEffect %s\n", loc)
+	}
+	if stalemode == "fail" && len(stale) > 0 && code == 0 {
+		code = 1
+	}
+	os.Exit(code)
+}
+
+// staleEffects returns the effects from the test files in the current directory that didn't run.
+func staleEffects() ([]internal.Location, error) {
+	fnames, err := filepath.Glob("*_test.go")
+	if err != nil {
+		return nil, err
+	}
+	var stale []internal.Location
+	for _, fname := range fnames {
+		if fname, err = filepath.Abs(fname); err != nil {
+			return nil, err
+		}
+		locs, err := internal.Effects(fname)
+		if err != nil {
+			return nil, err
+		}
+		for _, loc := range locs {
+			if !defaultReplacer.Hits[loc] {
+				stale = append(stale, loc)
+			}
+		}
+	}
+	return stale, nil
+}
+
+// reportOutcome fails the test if it has total expectations of the kind, accepted of them are updated at the end.
+func reportOutcome(kind, verb string, total, accepted int) {
+	t.Helper()
+	switch {
+	case total == 0:
+	case !updatemode:
+		t.Errorf("efft.%s: run with EFFUP=1 envvar to %s them", kind, verb)
+	case accepted == total:
+		t.Errorf("efft.%s: will update them at end", kind)
+	case accepted == 0:
+		t.Errorf("efft.%s: rejected them in the review", kind)
+	default:
+		t.Errorf("efft.%s: will update %d of them at end, rejected %d in the review", kind, accepted, total-accepted)
+	}
+}
+
+// review asks the user about each replacement on the terminal and returns the accepted ones.
+func review(replacements map[internal.Location]string) map[internal.Location]string {
+	t.Helper()
+	if err := openReviewer(); err != nil {
+		t.Errorf("efft.OpenTerminal: %v", err)
+		return nil
+	}
+	accepted := map[internal.Location]string{}
+	for _, loc := range slices.SortedFunc(maps.Keys(replacements), compareLocations) {
+		got, m := replacements[loc], mismatches[loc]
+		diff := m.diff
+		if defaultReplacer.Incomplete[loc] {
+			diff = "+" + strings.ReplaceAll(got, "\n", "\n+") + "\n"
+		}
+		if reviewer.Review(loc, m.note, diff) {
+			accepted[loc] = got
+		}
+	}
+	return accepted
+}
+
+// openReviewer sets up the reviewer on the terminal unless it's already set up.
+func openReviewer() error {
+	if reviewer != nil {
+		return nil
+	}
+	if answers := os.Getenv("EFFTESTING_TTY"); answers != "" {
+		// The tests answer the questions from a file.
+		f, err := os.Open(answers)
+		if err != nil {
+			return err
+		}
+		reviewer = &internal.Reviewer{In: bufio.NewReader(f), Out: os.Stderr}
+		return nil
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	reviewer = &internal.Reviewer{In: bufio.NewReader(tty), Out: tty}
+	return nil
+}
+
+func compareLocations(a, b internal.Location) int {
+	return cmp.Or(cmp.Compare(a.Fname, b.Fname), cmp.Compare(a.Line, b.Line))
+}
+
+// report appends the effect's outcome to the EFFREPORT file if it's enabled.
+func report(r result, status, want, note string) {
+	if reportPath == "" {
+		return
+	}
+	t.Helper()
+	if reportFile == nil {
+		f, err := os.OpenFile(reportPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			t.Errorf("efft.OpenReport: %v", err)
+			reportPath = ""
+			return
+		}
+		reportFile = f
+	}
+	record := internal.Record{
+		Fname:    r.loc.Fname,
+		Line:     r.loc.Line,
+		Test:     t.Name(),
+		Status:   status,
+		Expected: want,
+		Got:      r.got,
+		Note:     note,
+		Fatal:    r.fatal,
+	}
+	js, err := json.Marshal(record)
+	if err != nil {
+		t.Errorf("efft.MarshalRecord: %v", err)
+		return
+	}
+	if _, err := reportFile.Write(append(js, '\n')); err != nil {
+		t.Errorf("efft.WriteReport: %v", err)
+	}
+}
+
 func checkT() {
 	if t == nil {
 		pc, filename, _, _ := runtime.Caller(2)
@@ -151,24 +342,52 @@ type result struct {
 	got   string
 	loc   internal.Location
 	fatal bool
+	diff  func(want, got string) string // overrides Diff if non-nil
+	// invalid means that the effect already failed the test, Equals neither checks nor updates the expectation.
+	invalid bool
+}
+
+// track remembers the effect and the current note until its Equals is called.
+func track(r result) result {
+	if r.loc.Fname != "" {
+		pending[r.loc] = r
+		mismatches[r.loc] = mismatch{note: Note}
+	}
+	return r
 }
 
 func (r result) Equals(wanted expectationString) {
+	t.Helper()
+	if runMainTarget != 0 {
+		// The RunMain subprocess replays the test only to reach its RunMain call, the parent checks the expectations.
+		return
+	}
+	if r.invalid {
+		return
+	}
 	got, want := r.got, internal.Detab(string(wanted))
 	delete(defaultReplacer.Incomplete, r.loc)
+	delete(pending, r.loc)
 	if got == want {
 		delete(defaultReplacer.Replacements, r.loc)
+		report(r, "ok", want, Note)
 		return
 	}
-	t.Helper()
 	var note string
 	if Note != "" {
 		note = "note=`" + Note + "` "
 	}
+	diff := Diff
+	if r.diff != nil {
+		diff = r.diff
+	}
+	d := diff(want, got)
+	mismatches[r.loc] = mismatch{Note, d}
+	report(r, "wrong", want, Note)
 	if updatemode || !r.fatal {
-		t.Errorf("efft.EffectDiff %s-expectation +runtime:\n%s", note, Diff(want, got))
+		t.Errorf("efft.EffectDiff %s-expectation +runtime:\n%s", note, d)
 	} else {
-		t.Fatalf("efft.FatalEffectDiff %s-expectation +runtime:\n%s", note, Diff(string(want), got))
+		t.Fatalf("efft.FatalEffectDiff %s-expectation +runtime:\n%s", note, d)
 	}
 }
 
@@ -179,16 +398,16 @@ func (r result) Equals(wanted expectationString) {
 func Effect(args ...any) result { //revive:disable-line:unexported-return
 	checkT()
 	t.Helper()
-	got := Stringify(args...)
-	return result{got, defaultReplacer.Replace(got), false}
+	got := scrub(Stringify(args...))
+	return track(result{got, defaultReplacer.Replace(got), false, nil, false})
 }
 
 // FatalEffect is same as Effect but aborts the test if the expectation doesn't match.
 func FatalEffect(args ...any) result { //revive:disable-line:unexported-return
 	checkT()
 	t.Helper()
-	got := Stringify(args...)
-	return result{got, defaultReplacer.Replace(got), true}
+	got := scrub(Stringify(args...))
+	return track(result{got, defaultReplacer.Replace(got), true, nil, false})
 }
 
 // Context is the number of lines to display before and after the diff starts and ends.
@@ -208,6 +427,58 @@ func markTrailingSpace(s string) string {
 	return spaceDisplayer.Replace(s)
 }
 
+// invisibleNames are the markers of the common invisible characters in the diffs.
+var invisibleNames = map[rune]string{
+	'\r':     "CR",
+	'\u00a0': "NBSP",
+	'\u200b': "ZWSP",
+	'\u200c': "ZWNJ",
+	'\u200d': "ZWJ",
+	'\u2060': "WJ",
+	'\ufeff': "BOM",
+}
+
+// markInvisible makes the trailing whitespace and the invisible characters of a diff line visible.
+// The combining marks are marked only in the lines that are not in the NFC form.
+func markInvisible(s string) string {
+	s = markTrailingSpace(s)
+	nfc := norm.NFC.IsNormalString(s)
+	invisible := func(r rune) bool {
+		return invisibleNames[r] != "" || r != '\t' && (unicode.IsControl(r) || unicode.IsSpace(r) && r != ' ') ||
+			unicode.Is(unicode.Cf, r) || !nfc && unicode.Is(unicode.Mn, r)
+	}
+	if !strings.ContainsFunc(s, invisible) {
+		return s
+	}
+	sb := &strings.Builder{}
+	for _, r := range s {
+		switch {
+		case invisibleNames[r] != "":
+			sb.WriteString("⟨" + invisibleNames[r] + "⟩")
+		case invisible(r):
+			fmt.Fprintf(sb, "⟨U+%04X⟩", r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// normalizeLine removes the invisible differences from a line: the CR, the zero-width characters and the BOM.
+// It also replaces the NBSP with a space and brings the line to the NFC form.
+func normalizeLine(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\r' || r == '\ufeff' || r >= '\u200b' && r <= '\u200d' || r == '\u2060':
+			return -1
+		case r == '\u00a0':
+			return ' '
+		}
+		return r
+	}, s)
+	return norm.NFC.String(s)
+}
+
 func dummydiff(lts, rts string) string {
 	if lts == rts {
 		return ""
@@ -223,16 +494,21 @@ func dummydiff(lts, rts string) string {
 	}
 	d := make([]string, 0, 2*Context+len(lt)-commonStart-commonEnd+len(rt)-commonStart-commonEnd)
 	for i := max(0, commonStart-Context); i < commonStart; i++ {
-		d = append(d, " "+markTrailingSpace(lt[i]))
+		d = append(d, " "+markInvisible(lt[i]))
 	}
 	for i := commonStart; i < len(lt)-commonEnd; i++ {
-		d = append(d, "-"+markTrailingSpace(lt[i]))
+		d = append(d, "-"+markInvisible(lt[i]))
 	}
 	for i := commonStart; i < len(rt)-commonEnd; i++ {
-		d = append(d, "+"+markTrailingSpace(rt[i]))
+		d = append(d, "+"+markInvisible(rt[i]))
 	}
 	for i := len(lt) - commonEnd; i < min(len(lt), len(lt)-commonEnd+Context); i++ {
-		d = append(d, " "+markTrailingSpace(lt[i]))
+		d = append(d, " "+markInvisible(lt[i]))
+	}
+	for i := commonStart; i < len(lt)-commonEnd && i < len(rt)-commonEnd; i++ {
+		if lt[i] != rt[i] && normalizeLine(lt[i]) == normalizeLine(rt[i]) {
+			d = append(d, fmt.Sprintf("efft.NormalizationNotice: %+q and %+q differ only in invisible characters or Unicode normalization", lt[i], rt[i]))
+		}
 	}
 	return strings.Join(d, "\n") + "\n"
 }
@@ -1,11 +1,36 @@
 package efft_test
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"flag"
 	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/ypsu/efftesting/efft"
 	"github.com/ypsu/efftesting/efft/internal"
@@ -18,6 +43,13 @@ func TestEffect(t *testing.T) {
 	efft.Effect(0).Equals("0")
 	efft.Effect(-43).Equals("-43")
 	efft.Effect("blah").Equals("blah")
+	efft.Effect([]byte("text\n\tbytes")).Equals(`
+		text
+			bytes`)
+	efft.Effect([]byte("\x00binary\xff data that spans over multiple lines")).Equals(`
+		00000000  00 62 69 6e 61 72 79 ff  20 64 61 74 61 20 74 68  |.binary. data th|
+		00000010  61 74 20 73 70 61 6e 73  20 6f 76 65 72 20 6d 75  |at spans over mu|
+		00000020  6c 74 69 70 6c 65 20 6c  69 6e 65 73              |ltiple lines|`)
 	efft.Effect([]int{1, 2, 3, 4, 5}).Equals(`
 		[
 		  1,
@@ -110,34 +142,1198 @@ func TestEffect(t *testing.T) {
 	efft.Effect(func() (int, string, error) { return 1, "result2", fmt.Errorf("SomeError") }()).Equals("SomeError")
 }
 
+func TestCompactWidth(t *testing.T) {
+	efft.Init(t)
+	efft.Override(&efft.CompactWidth, 30)
+	efft.Effect([]int{1, 2, 3, 4, 5}).Equals("[1, 2, 3, 4, 5]")
+	efft.Effect(map[string]any{"a": []int{}, "b": map[string]int{}, "c": "<tag>", "d": 1.5, "e": nil}).Equals(`
+		{
+		  "a": [],
+		  "b": {},
+		  "c": "\u003ctag\u003e",
+		  "d": 1.5,
+		  "e": null
+		}`)
+	efft.Effect(map[string]any{
+		"short": []int{1, 2},
+		"long":  []string{"one", "two", "three", "four", "five"},
+		"deep":  map[string]any{"x": []any{1, map[string]int{"y": 2}}, "z": strings.Repeat("z", 20)},
+	}).Equals(`
+		{
+		  "deep": {
+		    "x": [1, {"y": 2}],
+		    "z": "zzzzzzzzzzzzzzzzzzzz"
+		  },
+		  "long": [
+		    "one",
+		    "two",
+		    "three",
+		    "four",
+		    "five"
+		  ],
+		  "short": [1, 2]
+		}`)
+	efft.Effect([][]int{{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, {11, 12}}).Equals(`
+		[
+		  [
+		    1,
+		    2,
+		    3,
+		    4,
+		    5,
+		    6,
+		    7,
+		    8,
+		    9,
+		    10
+		  ],
+		  [11, 12]
+		]`)
+	efft.Effect("plain string").Equals("plain string")
+}
+
+func TestDiffInvisible(t *testing.T) {
+	efft.Init(t)
+	efft.Effect(efft.Diff("a\nb \nc", "a\nb\t\nc")).Equals(`
+		 a
+		-b·
+		+b≫	
+		 c
+		`)
+	efft.Effect(efft.Diff("line1\nline2", "line1\r\nline2")).Equals(`
+		-line1
+		+line1⟨CR⟩
+		 line2
+		efft.NormalizationNotice: "line1" and "line1\r" differ only in invisible characters or Unicode normalization
+		`)
+	efft.Effect(efft.Diff("non breaking", "non\u00a0breaking")).Equals(`
+		-non breaking
+		+non⟨NBSP⟩breaking
+		efft.NormalizationNotice: "non breaking" and "non\u00a0breaking" differ only in invisible characters or Unicode normalization
+		`)
+	efft.Effect(efft.Diff("joined", "jo\u200dined\u200b")).Equals(`
+		-joined
+		+jo⟨ZWJ⟩ined⟨ZWSP⟩
+		efft.NormalizationNotice: "joined" and "jo\u200dined\u200b" differ only in invisible characters or Unicode normalization
+		`)
+	efft.Effect(efft.Diff("text", "\ufefftext")).Equals(`
+		-text
+		+⟨BOM⟩text
+		efft.NormalizationNotice: "text" and "\ufefftext" differ only in invisible characters or Unicode normalization
+		`)
+	efft.Effect(efft.Diff("caf\u00e9", "cafe\u0301")).Equals(`
+		-café
+		+cafe⟨U+0301⟩
+		efft.NormalizationNotice: "caf\u00e9" and "cafe\u0301" differ only in invisible characters or Unicode normalization
+		`)
+	// The compatibility equivalent text looks different, so no notice for these.
+	efft.Effect(efft.Diff("x2 file", "x² ﬁle")).Equals(`
+		-x2 file
+		+x² ﬁle
+		`)
+	efft.Effect(efft.Diff("ABC", "ＡＢＣ")).Equals(`
+		-ABC
+		+ＡＢＣ
+		`)
+	efft.Effect(efft.Diff("नमस्ते", "नमस्कार")).Equals(`
+		-नमस्ते
+		+नमस्कार
+		`)
+}
+
+func TestEffectGo(t *testing.T) {
+	efft.Init(t)
+	efft.EffectGo([]byte("package gen\nimport \"fmt\"\nfunc Hello( name string )string{return fmt.Sprint(\"hello \",name)}\n")).Equals(`
+		package gen
+
+		import "fmt"
+
+		func Hello(name string) string { return fmt.Sprint("hello ", name) }
+		`)
+
+	efft.Override(&efft.TypeCheckGo, true)
+	efft.EffectGo([]byte("package gen\nconst (\nA = 1\nLonger = 2\n)\nvar X = A+Longer\n")).Equals(`
+		package gen
+
+		const (
+			A      = 1
+			Longer = 2
+		)
+
+		var X = A + Longer
+		`)
+
+	// The imports are resolved in the test's module.
+	efft.EffectGo([]byte("package gen\nimport (\n\"github.com/ypsu/efftesting/efft\"\n\"golang.org/x/text/unicode/norm\"\n)\nvar X = efft.Diff(\"a\", norm.NFC.String(\"b\"))\n")).Equals(`
+		package gen
+
+		import (
+			"github.com/ypsu/efftesting/efft"
+			"golang.org/x/text/unicode/norm"
+		)
+
+		var X = efft.Diff("a", norm.NFC.String("b"))
+		`)
+	_, err := efft.FormatGo([]byte("package gen\nimport \"github.com/ypsu/efftesting/efft/missing\"\nvar X = missing.X\n"))
+	msg, _, _ := strings.Cut(err.Error(), " (") // the rest depends on the GOFLAGS and the GOPROXY
+	efft.Effect(msg).Equals("line=3 col=8: could not import github.com/ypsu/efftesting/efft/missing")
+
+	// The invalid sources.
+	efft.Effect(efft.FormatGo([]byte("package gen\nfunc F() {\n\treturn 1 +\n}\n"))).Equals(`
+		line=4 col=1: expected operand, found '}'
+			}
+			^`)
+	efft.Effect(efft.FormatGo([]byte("package gen\nfunc F() {\n\tvar s string = 1\n\t_ = s\n}\n\nvar X int = \"x\"\n"))).Equals(`
+		line=4 col=17: cannot use 1 (untyped int constant) as string value in variable declaration
+				var s string = 1
+				               ^
+		line=8 col=13: cannot use "x" (untyped string constant) as int value in variable declaration
+			var X int = "x"
+			            ^`)
+	efft.Effect(efft.FormatGo([]byte("package gen\nvar X = 1\nvar X = Y\n"))).Equals(`
+		line=4 col=5: X redeclared in this block
+			var X = Y
+			    ^
+		line=4 col=9: undefined: Y
+			var X = Y
+			        ^`)
+	efft.Effect(efft.FormatGo([]byte("x := 1\n"))).Equals("efft.ParseFile: 1:1: expected 'package', found x")
+	efft.Effect(efft.FormatGo([]byte("package gen\nvar (\n" + strings.Repeat("\t_ int = \"x\"\n", 12) + ")\n"))).Equals(`
+		line=4 col=10: cannot use "x" (untyped string constant) as int value in variable declaration
+				_ int = "x"
+				        ^
+		line=5 col=10: cannot use "x" (untyped string constant) as int value in variable declaration
+				_ int = "x"
+				        ^
+		line=6 col=10: cannot use "x" (untyped string constant) as int value in variable declaration
+				_ int = "x"
+				        ^
+		line=7 col=10: cannot use "x" (untyped string constant) as int value in variable declaration
+				_ int = "x"
+				        ^
+		line=8 col=10: cannot use "x" (untyped string constant) as int value in variable declaration
+				_ int = "x"
+				        ^
+		line=9 col=10: cannot use "x" (untyped string constant) as int value in variable declaration
+				_ int = "x"
+				        ^
+		line=10 col=10: cannot use "x" (untyped string constant) as int value in variable declaration
+				_ int = "x"
+				        ^
+		line=11 col=10: cannot use "x" (untyped string constant) as int value in variable declaration
+				_ int = "x"
+				        ^
+		line=12 col=10: cannot use "x" (untyped string constant) as int value in variable declaration
+				_ int = "x"
+				        ^
+		line=13 col=10: cannot use "x" (untyped string constant) as int value in variable declaration
+				_ int = "x"
+				        ^
+		(2 more errors)`)
+}
+
+// TestEffectGoInvalid checks that the invalid source fails the test without a diff and without updating the expectation.
+func TestEffectGoInvalid(t *testing.T) {
+	efft.Init(t)
+	src := `
+		package tmpmod
+
+		import (
+			"testing"
+
+			"github.com/ypsu/efftesting/efft"
+		)
+
+		func TestGen(t *testing.T) {
+			efft.Init(t)
+			efft.Override(&efft.TypeCheckGo, true)
+			efft.EffectGo([]byte("package gen\nvar X int = \"x\"\n")).Equals("package gen\n\nvar X int = 1\n")
+		}
+		`
+	dir := tempModule(t, map[string]string{"a_test.go": src})
+	output, err := goCmd(dir, nil, "test", "-count=1", ".").CombinedOutput()
+	efft.Effect(durationRE.ReplaceAllString(string(output), "1.0s")).Equals(`
+		--- FAIL: TestGen (1.0s)
+		    a_test.go:12: efft.InvalidGo:
+		        line=3 col=13: cannot use "x" (untyped string constant) as int value in variable declaration
+		        	var X int = "x"
+		        	            ^
+		FAIL
+		FAIL	tmpmod	1.0s
+		FAIL
+		`)
+	efft.Effect(err).Equals("exit status 1")
+	output, err = goCmd(dir, []string{"EFFUP=1"}, "test", "-count=1", ".").CombinedOutput()
+	efft.Effect(durationRE.ReplaceAllString(string(output), "1.0s")).Equals(`
+		--- FAIL: TestGen (1.0s)
+		    a_test.go:12: efft.InvalidGo:
+		        line=3 col=13: cannot use "x" (untyped string constant) as int value in variable declaration
+		        	var X int = "x"
+		        	            ^
+		FAIL
+		FAIL	tmpmod	1.0s
+		FAIL
+		`)
+	efft.Effect(err).Equals("exit status 1")
+	efft.Effect(string(efft.Must1(os.ReadFile(filepath.Join(dir, "a_test.go")))) == internal.Detab(src)).Equals("true")
+}
+
+func TestEffectImage(t *testing.T) {
+	efft.Init(t)
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 4))
+	for x := range 8 {
+		img.Set(x, x/2, color.NRGBA{0, 0, 255, 255})
+	}
+	efft.EffectImage(img).Equals("8x4 sha256=1a2930e4822ccd081583367c61f5030fa1f3528280aebf3f86c4cb56f1e0b172")
+
+	// The golden file has the original pixel, the difference is within the tolerance.
+	img.Set(0, 3, color.NRGBA{1, 1, 1, 1})
+	efft.Override(&efft.ImageTolerance, 1)
+	efft.EffectImage(img).Equals("8x4 sha256=1a2930e4822ccd081583367c61f5030fa1f3528280aebf3f86c4cb56f1e0b172")
+}
+
+func TestImageMismatch(t *testing.T) {
+	efft.Init(t)
+	dir := t.TempDir()
+	efft.Scrub(dir, "$DIR")
+	golden := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	for i := range golden.Pix {
+		golden.Pix[i] = 255
+	}
+	for x := range 8 {
+		golden.Set(x, x/2, color.RGBA{0, 0, 255, 255})
+	}
+	buf := &bytes.Buffer{}
+	efft.Must(png.Encode(buf, golden))
+	path := filepath.Join(dir, "golden.png")
+	efft.Must(os.WriteFile(path, buf.Bytes(), 0644))
+
+	img := image.NewRGBA(golden.Rect)
+	copy(img.Pix, golden.Pix)
+	efft.Effect(efft.CompareImage(img, path)).Equals("")
+
+	// Two changed pixels and one within the tolerance.
+	efft.Override(&efft.ImageTolerance, 1)
+	img.Set(5, 0, color.RGBA{0, 255, 0, 255})
+	img.Set(6, 3, color.RGBA{255, 255, 255, 255})
+	img.Set(0, 3, color.RGBA{254, 254, 254, 255})
+	efft.Effect(efft.CompareImage(img, path)).Equals("efft.ImageDiff file=$DIR/golden.png pixels=2 bounds=(5,0)-(7,4)")
+
+	diffPath := efft.Must1(efft.WriteDiffImage(golden, img, path))
+	defer os.Remove(diffPath)
+	efft.Effect(strings.HasSuffix(diffPath, "-golden.diff.png")).Equals("true")
+	diff := efft.Must1(png.Decode(bytes.NewReader(efft.Must1(os.ReadFile(diffPath)))))
+	art := &strings.Builder{}
+	for y := range diff.Bounds().Dy() {
+		for x := range diff.Bounds().Dx() {
+			switch r, g, b, _ := diff.At(x, y).RGBA(); {
+			case r == 0xffff && g == 0 && b == 0:
+				art.WriteByte('R') // changed
+			case r == 0xffff:
+				art.WriteByte('.') // white
+			default:
+				art.WriteByte('#') // faded golden pixel
+			}
+		}
+		art.WriteByte('\n')
+	}
+	efft.Effect(art.String()).Equals(`
+		##...R..
+		..##....
+		....##..
+		......R#
+		`)
+
+	efft.Effect(efft.CompareImage(image.NewRGBA(image.Rect(0, 0, 4, 4)), path)).Equals("efft.ImageSizeDiff file=$DIR/golden.png golden=(8,4) runtime=(4,4)")
+	efft.Effect(efft.CompareImage(img, filepath.Join(dir, "missing.png"))).Equals("efft.MissingGolden file=$DIR/missing.png: run with EFFUP=1 envvar to create it")
+	efft.Must(os.WriteFile(path, []byte("not a png"), 0644))
+	efft.Effect(efft.CompareImage(img, path)).Equals("efft.ReadGolden file=$DIR/golden.png: png: invalid format: not a PNG file")
+}
+
+func explode(v any) {
+	panic(v)
+}
+
+func TestEffectPanic(t *testing.T) {
+	efft.Init(t)
+	efft.EffectPanic(func() {}).Equals("no panic")
+	efft.EffectPanic(func() (int, error) { return 1, nil }).Equals("no panic: 1")
+	efft.EffectPanic(func() { explode("boom") }).Equals("panic: boom")
+	efft.EffectPanic(func() { explode(fmt.Errorf("SomeError")) }).Equals("panic: SomeError")
+	efft.EffectPanic(func() { explode([]int{1, 2}) }).Equals(`
+		panic: [
+		  1,
+		  2
+		]`)
+	efft.EffectPanic(func() int { return []int{}[1] }).Equals("panic: runtime error: index out of range [1] with length 0")
+
+	efft.Override(&efft.PanicStack, true)
+	efft.EffectPanic(func() { explode("boom") }).Equals(`
+		panic: boom
+		stack:
+		  efft_test.explode (effect_test.go)
+		  efft_test.TestEffectPanic.func7 (effect_test.go)`)
+	efft.EffectPanic(func() string { return "no stack without panic" }).Equals("no panic: no stack without panic")
+}
+
+func TestEffectOutput(t *testing.T) {
+	efft.Init(t)
+	stdout := os.Stdout
+	efft.EffectOutput(func() {}).Equals("")
+	efft.EffectOutput(func() {
+		fmt.Println("hello")
+		fmt.Fprint(os.Stderr, "partial")
+		fmt.Print("multi\nline\n")
+		log.Printf("log %d", 1)
+		fmt.Fprintln(os.Stderr, "error")
+		slog.Info("slog", "key", "value")
+		fmt.Println("bye")
+	}).Equals(`
+		stdout: hello
+		stdout: multi
+		stdout: line
+		stderr: partial
+		log: log 1
+		stderr: error
+		slog: level=INFO msg=slog key=value
+		stdout: bye`)
+	efft.Effect(os.Stdout == stdout).Equals("true")
+}
+
+func TestLogRecorder(t *testing.T) {
+	efft.Init(t)
+	rec := &efft.LogRecorder{}
+	logger := slog.Default()
+	efft.Override(&logger, slog.New(rec))
+	logger.Debug("starting", "port", 8080)
+	logger = logger.With("service", "api")
+	logger.Info("request", slog.Group("req", "path", "/a b", "id", 7, "empty", ""), "status", 200)
+	logger.WithGroup("db").With("table", "users").Warn("slow query", "ms", 1500)
+	logger.Error("failed", "err", fmt.Errorf("SomeError"))
+	efft.Effect(rec).Equals(`
+		DEBUG starting port=8080
+		INFO request service=api req.empty="" req.id=7 req.path="/a b" status=200
+		WARN slow query service=api db.table=users db.ms=1500
+		ERROR failed service=api err=SomeError`)
+
+	rec = &efft.LogRecorder{Level: slog.LevelWarn}
+	efft.Override(&logger, slog.New(rec))
+	logger.Info("skipped")
+	logger.Warn("recorded")
+	efft.Effect(rec).Equals("WARN recorded")
+}
+
+func TestTrace(t *testing.T) {
+	efft.Init(t)
+	tr := efft.Trace()
+	efft.Effect(tr).Equals("")
+	tr.Log("start")
+	tr.Logf("state=%s n=%d", "open", 1)
+	tr.Log("multi\nline")
+	efft.Effect(tr).Equals(`
+		start
+		state=open n=1
+		multi
+		line`)
+
+	tr = efft.Trace()
+	tr.Lanes = true
+	tr.Log("main")
+	var wg sync.WaitGroup
+	for _, name := range []string{"b", "a", "c"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 2 {
+				tr.Logf("%s%d", name, i)
+			}
+		}()
+	}
+	wg.Wait()
+	efft.Effect(tr).Equals(`
+		lane 1:
+		  a0
+		  a1
+		lane 2:
+		  b0
+		  b1
+		lane 3:
+		  c0
+		  c1
+		lane 4:
+		  main`)
+}
+
+func TestGenerateFakes(t *testing.T) {
+	efft.Init(t)
+	src := `
+		package store
+
+		import "context"
+
+		type Store interface {
+			Get(ctx context.Context, key string) (string, error)
+			Put(key, value string) error
+			Keys(prefixes ...string) []string
+			Reset()
+		}
+
+		type NotInterface int
+	`
+	fset := token.NewFileSet()
+	f := efft.Must1(parser.ParseFile(fset, "store.go", src, 0))
+	pkg := efft.Must1((&types.Config{Importer: importer.Default()}).Check("store", fset, []*ast.File{f}, nil))
+	efft.Effect(internal.GenerateFakes(pkg, []string{"Store"})).Equals(`
+		// Code generated by efftgen; DO NOT EDIT.
+
+		package store
+
+		import (
+			"context"
+			"github.com/ypsu/efftesting/efft"
+		)
+
+		// FakeStore is a recording fake of Store.
+		// The <Method>Func fields implement the methods, the methods without them return zero values.
+		type FakeStore struct {
+			GetFunc   func(p0 context.Context, p1 string) (string, error)
+			KeysFunc  func(p0 ...string) []string
+			PutFunc   func(p0 string, p1 string) error
+			ResetFunc func()
+
+			calls efft.Tracer
+		}
+
+		// Calls returns the trace of the method calls.
+		func (f *FakeStore) Calls() *efft.Tracer {
+			return &f.calls
+		}
+
+		func (f *FakeStore) Get(p0 context.Context, p1 string) (string, error) {
+			var r0 string
+			var r1 error
+			if f.GetFunc != nil {
+				r0, r1 = f.GetFunc(p0, p1)
+			}
+			f.calls.LogCall("Get", []any{p0, p1}, []any{r0, r1})
+			return r0, r1
+		}
+
+		func (f *FakeStore) Keys(p0 ...string) []string {
+			var r0 []string
+			if f.KeysFunc != nil {
+				r0 = f.KeysFunc(p0...)
+			}
+			f.calls.LogCall("Keys", []any{p0}, []any{r0})
+			return r0
+		}
+
+		func (f *FakeStore) Put(p0 string, p1 string) error {
+			var r0 error
+			if f.PutFunc != nil {
+				r0 = f.PutFunc(p0, p1)
+			}
+			f.calls.LogCall("Put", []any{p0, p1}, []any{r0})
+			return r0
+		}
+
+		func (f *FakeStore) Reset() {
+			if f.ResetFunc != nil {
+				f.ResetFunc()
+			}
+			f.calls.LogCall("Reset", []any{}, []any{})
+		}
+		`)
+	efft.Effect(internal.GenerateFakes(pkg, []string{"NotInterface"})).Equals("efft.NotInterface type=NotInterface")
+	efft.Effect(internal.GenerateFakes(pkg, []string{"Missing"})).Equals("efft.TypeNotFound type=Missing")
+
+	// Two imported packages with the same name.
+	named := func(path string, underlying types.Type) *types.Named {
+		p := types.NewPackage(path, "util")
+		return types.NewNamed(types.NewTypeName(token.NoPos, p, "ID", nil), underlying, nil)
+	}
+	clash := types.NewPackage("example.com/clash", "clash")
+	sig := types.NewSignatureType(nil, nil, nil,
+		types.NewTuple(types.NewParam(token.NoPos, clash, "id", named("example.com/a/util", types.Typ[types.Int]))),
+		types.NewTuple(types.NewParam(token.NoPos, clash, "", named("example.com/b/util", types.Typ[types.String]))), false)
+	iface := types.NewInterfaceType([]*types.Func{types.NewFunc(token.NoPos, clash, "Convert", sig)}, nil).Complete()
+	clash.Scope().Insert(types.NewTypeName(token.NoPos, clash, "Converter", iface))
+	efft.Effect(internal.GenerateFakes(clash, []string{"Converter"})).Equals(`
+		// Code generated by efftgen; DO NOT EDIT.
+
+		package clash
+
+		import (
+			"example.com/a/util"
+			util2 "example.com/b/util"
+			"github.com/ypsu/efftesting/efft"
+		)
+
+		// FakeConverter is a recording fake of Converter.
+		// The <Method>Func fields implement the methods, the methods without them return zero values.
+		type FakeConverter struct {
+			ConvertFunc func(p0 util.ID) util2.ID
+
+			calls efft.Tracer
+		}
+
+		// Calls returns the trace of the method calls.
+		func (f *FakeConverter) Calls() *efft.Tracer {
+			return &f.calls
+		}
+
+		func (f *FakeConverter) Convert(p0 util.ID) util2.ID {
+			var r0 util2.ID
+			if f.ConvertFunc != nil {
+				r0 = f.ConvertFunc(p0)
+			}
+			f.calls.LogCall("Convert", []any{p0}, []any{r0})
+			return r0
+		}
+		`)
+
+	tr := efft.Trace()
+	tr.LogCall("Get", []any{context.Background(), "key"}, []any{"", fmt.Errorf("SomeError")})
+	tr.LogCall("Put", []any{[]string{"a"}, map[string]int{"b": 1}, (*int)(nil)}, nil)
+	efft.Effect(tr).Equals(`
+		Get(context.Background, "key") = "", SomeError
+		Put(["a"], {"b":1}, nil)`)
+}
+
+func TestHTTP(t *testing.T) {
+	efft.Init(t)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("X-Multi", "b")
+		w.Header().Add("X-Multi", "a")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"path":%q,"got":%s}`, r.URL.Path, body)
+	})
+
+	req := httptest.NewRequest("POST", "/items?id=1", strings.NewReader(`{"name":"x","tags":["a","b"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	efft.Effect(req).Equals(`
+		POST /items?id=1 HTTP/1.1
+		Host: example.com
+		Content-Type: application/json
+
+		{
+		  "name": "x",
+		  "tags": [
+		    "a",
+		    "b"
+		  ]
+		}`)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	efft.Effect(rec).Equals(`
+		HTTP/1.1 201 Created
+		Content-Type: application/json
+		X-Multi: b
+		X-Multi: a
+
+		{
+		  "path": "/items",
+		  "got": {
+		    "name": "x",
+		    "tags": [
+		      "a",
+		      "b"
+		    ]
+		  }
+		}`)
+	efft.Effect(rec.Header()).Equals(`
+		Content-Type: application/json
+		X-Multi: b
+		X-Multi: a`)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	resp := efft.Must1(http.Post(server.URL+"/plain", "text/plain", strings.NewReader("1")))
+	defer resp.Body.Close()
+	efft.Effect(resp).Equals(`
+		HTTP/1.1 201 Created
+		Content-Length: 25
+		Content-Type: application/json
+		Date: [scrubbed]
+		X-Multi: b
+		X-Multi: a
+
+		{
+		  "path": "/plain",
+		  "got": 1
+		}`)
+	efft.Effect(string(efft.Must1(io.ReadAll(resp.Body)))).Equals("{\"path\":\"/plain\",\"got\":1}")
+	efft.Effect(httptest.NewRequest("GET", "http://api.example.com/a%20b?x=1", nil)).Equals(`
+		GET /a%20b?x=1 HTTP/1.1
+		Host: api.example.com`)
+}
+
+func TestCassette(t *testing.T) {
+	efft.Init(t)
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"method":%q,"path":%q,"got":%q}`+"\n", r.Method, r.URL.Path, body)
+	}))
+	defer server.Close()
+
+	transport := efft.Cassette(t, "testdata/cassette.txt")
+	client := &http.Client{Transport: transport}
+	resp := efft.Must1(client.Get(server.URL + "/users?page=1"))
+	efft.Effect(resp.StatusCode, string(efft.Must1(io.ReadAll(resp.Body)))).Equals(`
+		[
+		  200,
+		  "{\"method\":\"GET\",\"path\":\"/users\",\"got\":\"\"}\n"
+		]`)
+	// RoundTrip must not modify the request.
+	req := efft.Must1(http.NewRequest(http.MethodPost, server.URL+"/users", strings.NewReader("alice")))
+	body := req.Body
+	resp = efft.Must1(transport.RoundTrip(req))
+	efft.Effect(req.Body == body).Equals("true")
+	efft.Effect(resp).Equals(`
+		HTTP/1.1 200 OK
+		Content-Length: 48
+		Content-Type: application/json
+
+		{
+		  "method": "POST",
+		  "path": "/users",
+		  "got": "alice"
+		}`)
+	if os.Getenv("EFFUP") == "" {
+		efft.Effect(hits).Equals("0")
+	}
+}
+
+// TestCassetteMismatch checks the failures of a wrong cassette and that EFFUP=1 rerecords it.
+func TestCassetteMismatch(t *testing.T) {
+	efft.Init(t)
+	dir := tempModule(t, map[string]string{
+		"a_test.go": `
+			package tmpmod
+
+			import (
+				"fmt"
+				"net/http"
+				"net/http/httptest"
+				"testing"
+
+				"github.com/ypsu/efftesting/efft"
+			)
+
+			func TestCassette(t *testing.T) {
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprintf(w, "hello %s", r.URL.Path)
+				}))
+				defer server.Close()
+				client := &http.Client{Transport: efft.Cassette(t, "cassette.txt")}
+				for _, path := range []string{"/a", "/b"} {
+					resp, err := client.Get(server.URL + path)
+					if err != nil {
+						t.Logf("get %s failed", path)
+						continue
+					}
+					resp.Body.Close()
+				}
+			}
+			`,
+		"cassette.txt": `
+			-- request --
+			GET /a HTTP/1.1
+			-- response --
+			HTTP/1.1 200 OK
+
+			hello /a
+			-- request --
+			GET /c HTTP/1.1
+			-- response --
+			HTTP/1.1 200 OK
+
+			hello /c
+			`,
+	})
+	cassette := filepath.Join(dir, "cassette.txt")
+	output, err := goCmd(dir, nil, "test", "-count=1", ".").CombinedOutput()
+	efft.Effect(durationRE.ReplaceAllString(string(output), "1.0s")).Equals(`
+		--- FAIL: TestCassette (1.0s)
+		    cassette.go:109: efft.UnmatchedRequest file=cassette.txt:
+		        GET /b HTTP/1.1
+		    a_test.go:21: get /b failed
+		    a_test.go:17: efft.UnusedExchanges count=1
+		    a_test.go:17: efft.WrongCassette: run with EFFUP=1 envvar to fix it
+		FAIL
+		FAIL	tmpmod	1.0s
+		FAIL
+		`)
+	efft.Effect(err).Equals("exit status 1")
+
+	output, err = goCmd(dir, []string{"EFFUP=1"}, "test", "-count=1", ".").CombinedOutput()
+	efft.Effect(durationRE.ReplaceAllString(string(output), "1.0s")).Equals(`
+		--- FAIL: TestCassette (1.0s)
+		    a_test.go:17: efft.CassetteDiff file=cassette.txt -expectation +runtime:
+		        --- request --
+		        -GET /a HTTP/1.1
+		        --- response --
+		        -HTTP/1.1 200 OK
+		        -
+		        -hello /a
+		        --- request --
+		        -GET /c HTTP/1.1
+		        --- response --
+		        -HTTP/1.1 200 OK
+		        -
+		        -hello /c
+		        +# HTTP cassette, rerecord with EFFUP=1.
+		        +-- request --
+		        +GET /a HTTP/1.1
+		        +-- response --
+		        +HTTP/1.1 200 OK
+		        +Content-Length: 8
+		        +Content-Type: text/plain; charset=utf-8
+		        +
+		        +hello /a
+		        +-- request --
+		        +GET /b HTTP/1.1
+		        +-- response --
+		        +HTTP/1.1 200 OK
+		        +Content-Length: 8
+		        +Content-Type: text/plain; charset=utf-8
+		        +
+		        +hello /b
+		         
+		    a_test.go:17: efft.ExpectationsUpdatedSuccessfully file=cassette.txt
+		FAIL
+		FAIL	tmpmod	1.0s
+		FAIL
+		`)
+	efft.Effect(err).Equals("exit status 1")
+	efft.Effect(efft.Must1(os.ReadFile(cassette))).Equals(`
+		# HTTP cassette, rerecord with EFFUP=1.
+		-- request --
+		GET /a HTTP/1.1
+		-- response --
+		HTTP/1.1 200 OK
+		Content-Length: 8
+		Content-Type: text/plain; charset=utf-8
+
+		hello /a
+		-- request --
+		GET /b HTTP/1.1
+		-- response --
+		HTTP/1.1 200 OK
+		Content-Length: 8
+		Content-Type: text/plain; charset=utf-8
+
+		hello /b
+		`)
+	// The rerecorded cassette replays fine.
+	efft.Effect(goCmd(dir, nil, "test", "-count=1", ".").Run()).Equals("null")
+}
+
+func TestTable(t *testing.T) {
+	efft.Init(t)
+	type item struct {
+		ID      int    `json:"id"`
+		Name    string `json:"name,omitempty"`
+		Price   *float64
+		Created time.Time
+		Secret  string `json:"-"`
+		private int
+	}
+	price := 9.5
+	items := []item{
+		{1, "apple", &price, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), "s", 0},
+		{22, "ör\tvény", nil, time.Time{}, "s", 0},
+	}
+	efft.Effect(efft.Table(items)).Equals(`
+		id  | name     | Price    | Created
+		int | string   | *float64 | time.Time
+		----+----------+----------+---------------------
+		1   | apple    | 9.5      | 2024-01-02T00:00:00Z
+		22  | ör\tvény | nil      | 0001-01-01T00:00:00Z`)
+	efft.Effect(efft.Table([]*item{})).Equals(`
+		id  | name   | Price    | Created
+		int | string | *float64 | time.Time
+		----+--------+----------+----------`)
+	efft.Effect(efft.Table([]map[string]int{{"b": 2, "a": 1}, {"c": 3}})).Equals(`
+		a   | b   | c
+		int | int | int
+		----+-----+----
+		1   | 2   |
+		    |     | 3`)
+	efft.Effect(efft.Table([]int{1, 2})).Equals(`
+		[
+		  1,
+		  2
+		]`)
+	efft.Effect(efft.Table([]struct{ V []int }{{[]int{1}}})).Equals(`
+		[
+		  {
+		    "V": [
+		      1
+		    ]
+		  }
+		]`)
+
+	efft.Effect(items[:1]).Equals(`
+		[
+		  {
+		    "id": 1,
+		    "name": "apple",
+		    "Price": 9.5,
+		    "Created": "2024-01-02T00:00:00Z"
+		  }
+		]`)
+	efft.Override(&efft.TableWidth, 60)
+	efft.Effect(items[:1]).Equals(`
+		id  | name   | Price    | Created
+		int | string | *float64 | time.Time
+		----+--------+----------+---------------------
+		1   | apple  | 9.5      | 2024-01-02T00:00:00Z`)
+	efft.Override(&efft.TableWidth, 20)
+	efft.Effect(items[:1]).Equals(`
+		[
+		  {
+		    "id": 1,
+		    "name": "apple",
+		    "Price": 9.5,
+		    "Created": "2024-01-02T00:00:00Z"
+		  }
+		]`)
+}
+
+// fakeDriver is a database/sql driver whose queries return the fakeQueries results.
+type fakeDriver struct{}
+
+type fakeResult struct {
+	columns, types []string
+	rows           [][]driver.Value
+}
+
+var fakeQueries = map[string]fakeResult{
+	"SELECT * FROM users": {
+		[]string{"id", "name", "score", "created"},
+		[]string{"INTEGER", "TEXT", "REAL", "TIMESTAMP"},
+		[][]driver.Value{
+			{int64(2), "bob", nil, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+			{int64(1), "alice\nsmith", 1.5, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)},
+			{int64(10), []byte("ödön"), -2.25, nil},
+		},
+	},
+	"SELECT 1": {[]string{"1"}, []string{""}, [][]driver.Value{{int64(1)}}},
+}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt(query), nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, fmt.Errorf("NoTransactions") }
+
+type fakeStmt string
+
+func (fakeStmt) Close() error                               { return nil }
+func (fakeStmt) NumInput() int                              { return -1 }
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) { return nil, fmt.Errorf("NoExec") }
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	result, ok := fakeQueries[string(s)]
+	if !ok {
+		return nil, fmt.Errorf("UnknownQuery")
+	}
+	return &fakeRows{result, 0}, nil
+}
+
+type fakeRows struct {
+	fakeResult
+	next int
+}
+
+func (r *fakeRows) Columns() []string                       { return r.columns }
+func (r *fakeRows) ColumnTypeDatabaseTypeName(i int) string { return r.types[i] }
+func (r *fakeRows) Close() error                            { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next == len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
+
+func init() {
+	sql.Register("efftfake", fakeDriver{})
+}
+
+func TestSQLRows(t *testing.T) {
+	efft.Init(t)
+	db := efft.Must1(sql.Open("efftfake", ""))
+	defer db.Close()
+	efft.Effect(db.Query("SELECT * FROM users")).Equals(`
+		id      | name         | score | created
+		INTEGER | TEXT         | REAL  | TIMESTAMP
+		--------+--------------+-------+---------------------
+		2       | bob          | NULL  | 2024-01-02T03:04:05Z
+		1       | alice\nsmith | 1.5   | 2023-01-02T03:04:05Z
+		10      | ödön         | -2.25 | NULL`)
+	efft.Override(&efft.SortRows, true)
+	efft.Effect(db.Query("SELECT * FROM users")).Equals(`
+		id      | name         | score | created
+		INTEGER | TEXT         | REAL  | TIMESTAMP
+		--------+--------------+-------+---------------------
+		1       | alice\nsmith | 1.5   | 2023-01-02T03:04:05Z
+		10      | ödön         | -2.25 | NULL
+		2       | bob          | NULL  | 2024-01-02T03:04:05Z`)
+	efft.Effect(db.Query("SELECT 1")).Equals(`
+		1
+		interface {}
+		------------
+		1`)
+	efft.Effect(db.Query("SELECT 2")).Equals("UnknownQuery")
+}
+
+func fakeMain() {
+	code := flag.Int("code", 0, "the exit code")
+	flag.Parse()
+	input, _ := io.ReadAll(os.Stdin)
+	fmt.Printf("args=%q stdin=%q\n", flag.Args(), input)
+	if *code > 0 {
+		fmt.Fprintln(os.Stderr, "failing")
+	}
+	if *code != -1 {
+		os.Exit(*code)
+	}
+}
+
+func TestRunMain(t *testing.T) {
+	efft.Init(t)
+	efft.Effect(efft.RunMain(fakeMain, []string{"fake", "-code=-1", "arg"}, "")).Equals(`
+		exit 0
+		--- stdout
+		args=["arg"] stdin=""`)
+	efft.Effect(efft.RunMain(fakeMain, []string{"fake"}, "some input")).Equals(`
+		exit 0
+		--- stdout
+		args=[] stdin="some input"`)
+	efft.Effect(efft.RunMain(fakeMain, []string{"fake", "-code=3", "a", "b"}, "")).Equals(`
+		exit 3
+		--- stdout
+		args=["a" "b"] stdin=""
+		--- stderr
+		failing`)
+	efft.Effect(efft.RunMain(func() { panic("boom") }, nil, "").ExitCode).Equals("2")
+	efft.Effect(efft.RunResult{}).Equals("exit 0")
+}
+
+func TestRunMainReplay(t *testing.T) {
+	efft.Init(t)
+	// The subprocess of the last call replays these checks with empty results, they must not abort it.
+	efft.FatalEffect(efft.RunMain(fakeMain, []string{"fake", "first"}, "")).Equals(`
+		exit 0
+		--- stdout
+		args=["first"] stdin=""`)
+	efft.Must(efft.RunMain(fakeMain, []string{"fake"}, "").ExitCode == 0)
+	efft.Effect(efft.RunMain(fakeMain, []string{"fake", "last"}, "")).Equals(`
+		exit 0
+		--- stdout
+		args=["last"] stdin=""`)
+}
+
+func TestEffectCmd(t *testing.T) {
+	efft.Init(t)
+	dir := t.TempDir()
+	efft.Must(os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello\n"), 0644))
+	cmd := exec.Command("sh", "-c", `cat file.txt; echo "$PWD $HOME $LC_ALL $TZ"; echo "$1" >&2; exit 3`, "sh", t.TempDir())
+	cmd.Dir = dir
+	efft.EffectCmd(cmd).Equals(`
+		exit 3
+		--- stdout
+		hello
+		$WORK $HOME C UTC
+		--- stderr
+		$TMPDIR/002`)
+
+	efft.Scrub("hello", "bye")
+	cmd = exec.Command("cat", filepath.Join(dir, "file.txt"))
+	cmd.Env = []string{}
+	efft.EffectCmd(cmd).Equals(`
+		exit 0
+		--- stdout
+		bye`)
+	efft.Effect("hello world").Equals("bye world")
+}
+
+func TestEffectDir(t *testing.T) {
+	efft.Init(t)
+	dir := t.TempDir()
+	efft.Must(os.MkdirAll(filepath.Join(dir, "sub", "empty"), 0755))
+	efft.Must(os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello\nworld"), 0644))
+	efft.Must(os.WriteFile(filepath.Join(dir, "sub", "run.sh"), []byte("#!/bin/sh\necho hi\n"), 0755))
+	efft.Must(os.WriteFile(filepath.Join(dir, "sub", "blob"), []byte{0, 1, 2}, 0600))
+	// The modes above are subject to the umask.
+	for path, mode := range map[string]os.FileMode{"sub": 0755, "sub/empty": 0755, "a.txt": 0644, "sub/run.sh": 0755, "sub/blob": 0600} {
+		efft.Must(os.Chmod(filepath.Join(dir, path), mode))
+	}
+	efft.EffectDir(dir).Equals(`
+		-- a.txt (-rw-r--r--) --
+		hello
+		world
+		-- sub/ (drwxr-xr-x) --
+		-- sub/blob (-rw-------) --
+		binary size=3 sha256=ae4b3280e56e2faf83f414a6e3dabe9d5fbe18976544c05fed121accb85b53fc
+		-- sub/empty/ (drwxr-xr-x) --
+		-- sub/run.sh (-rwxr-xr-x) --
+		#!/bin/sh
+		echo hi
+		`)
+
+	efft.EffectFS(fstest.MapFS{"x/y.txt": {Data: []byte("y\n")}}).Equals(`
+		-- x/ (dr-xr-xr-x) --
+		-- x/y.txt (----------) --
+		y
+		`)
+	efft.EffectDir(filepath.Join(dir, "missing")).Equals("efft.WalkDir: stat .: no such file or directory")
+}
+
+func TestRunFile(t *testing.T) {
+	efft.RunFile(t, "testdata/datafile.txt", func(cmd, input string) string {
+		switch args := strings.Fields(cmd); args[0] {
+		case "upper":
+			return strings.ToUpper(input)
+		case "split":
+			return strings.Join(strings.Split(input, args[1]), "\n")
+		case "repeat":
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				return err.Error()
+			}
+			return strings.Repeat(input+"\n", n)
+		}
+		return "unknown command " + cmd
+	})
+}
+
+func TestRunScript(t *testing.T) {
+	efft.RunScript(t, "testdata/*.txtar", map[string]efft.ScriptCmd{
+		"upper": func(_ *efft.Script, args []string, _ string) string {
+			return strings.ToUpper(strings.Join(args, " "))
+		},
+	})
+}
+
+func TestDataFile(t *testing.T) {
+	efft.Init(t)
+	src := internal.Detab(`
+		cmd1
+		----
+		old
+
+		# comment
+		cmd2 arg
+		input1
+
+		input2
+		----
+		----
+		old
+
+		output
+		----
+		----
+		cmd3
+		----
+		`)
+	blocks := efft.Must1(internal.ParseDataFile("test.txt", src))
+	efft.Effect(blocks).Equals(`
+		[
+		  {
+		    "Line": 1,
+		    "Cmd": "cmd1",
+		    "Input": "",
+		    "Output": "old"
+		  },
+		  {
+		    "Line": 6,
+		    "Cmd": "cmd2 arg",
+		    "Input": "input1\n\ninput2",
+		    "Output": "old\n\noutput"
+		  },
+		  {
+		    "Line": 17,
+		    "Cmd": "cmd3",
+		    "Input": "",
+		    "Output": ""
+		  }
+		]`)
+	blocks[0].Output, blocks[1].Output, blocks[2].Output = "new\n\noutput", "new", "----\nx"
+	updated := internal.UpdateDataFile(src, blocks)
+	efft.Effect(updated).Equals(`
+		cmd1
+		----
+		----
+		new
+
+		output
+		----
+		----
+
+		# comment
+		cmd2 arg
+		input1
+
+		input2
+		----
+		new
+
+		cmd3
+		----
+		----
+		----
+		x
+		----
+		----
+		`)
+	reparsed := efft.Must1(internal.ParseDataFile("test.txt", updated))
+	efft.Effect(reparsed[0].Output == blocks[0].Output && reparsed[1].Output == blocks[1].Output && reparsed[2].Output == blocks[2].Output).Equals("true")
+
+	efft.Effect(internal.ParseDataFile("test.txt", "cmd\ninput\n")).Equals("efft.MissingSeparator test.txt:1")
+	efft.Effect(internal.ParseDataFile("test.txt", "cmd\n----\n----\noutput\n")).Equals("efft.MissingDoubleSeparator test.txt:1")
+}
+
 func TestReplacer(t *testing.T) {
 	efft.Init(t)
 	tmpfile := filepath.Join(t.TempDir(), "test.go")
 	testfile := internal.Detab(strings.ReplaceAll(`
 		package main
 
+		import "github.com/ypsu/efftesting/efft"
+
 		func TestSomething() {
 			efft.Init(t)
-			// line 5
+			// line 7
 			efft.Effect("somevalue").Equals("somevalue")
 			efft.Effect("newvalue")
-			efft.Effect( /* line 8 */ "newvalue").Equals(!oldvalue!)
-			efft.Effect("new\nvalue").Equals("oldvalue") // line 9
+			efft.Effect( /* line 10 */ "newvalue").Equals(!oldvalue!)
+			efft.Effect("new\nvalue").Equals("oldvalue") // line 11
 			efft.Effect("new value").Equals(!
 				one
 				two
 				three
-			!) // line 14
+			!) // line 16
 			efft.Effect("\nnew\n\nvalue").Equals("oldvalue")
 			go func() {
-				efft.Effect("newvalue").Equals("oldvalue") // line 17
+				efft.Effect("newvalue").Equals("oldvalue") // line 19
 			}()
 			efft.Effect("a", "b", "c").Equals("oldvalue")
 			efft.Effect("a", "b", "c").Equals()
 			efft.Effect("a", "b", "c").Equals("a", "b")
 			efft.Effect("a", "b", "c").Equals(3)
 			// some comment before
-			efft.Effect("y\nx").Equals("x\ny") // line 24
+			efft.Effect("y\nx").Equals("x\ny") // line 26
 			efft.Effect("y\nx").Equals("x\ny")
 			// some comment after
 		}
@@ -157,132 +1353,132 @@ func TestReplacer(t *testing.T) {
 	}
 
 	efft.Note = "no replacement"
-	efft.Effect(apply(6, "somevalue")).Equals("")
+	efft.Effect(apply(8, "somevalue")).Equals("")
 
 	efft.Note = "add expectation"
-	efft.Effect(apply(7, "newvalue")).Equals(`
-		 	// line 5
+	efft.Effect(apply(9, "newvalue")).Equals(`
+		 	// line 7
 		 	efft.Effect("somevalue").Equals("somevalue")
 		-	efft.Effect("newvalue")
 		+	efft.Effect("newvalue").Equals("newvalue")
-		 	efft.Effect( /* line 8 */ "newvalue").Equals(!oldvalue!)
-		 	efft.Effect("new\nvalue").Equals("oldvalue") // line 9
+		 	efft.Effect( /* line 10 */ "newvalue").Equals(!oldvalue!)
+		 	efft.Effect("new\nvalue").Equals("oldvalue") // line 11
 		`)
 
 	efft.Note = "simple replacement"
-	efft.Effect(apply(6, "newvalue")).Equals(`
+	efft.Effect(apply(8, "newvalue")).Equals(`
 		 	efft.Init(t)
-		 	// line 5
+		 	// line 7
 		-	efft.Effect("somevalue").Equals("somevalue")
 		+	efft.Effect("somevalue").Equals("newvalue")
 		 	efft.Effect("newvalue")
-		 	efft.Effect( /* line 8 */ "newvalue").Equals(!oldvalue!)
+		 	efft.Effect( /* line 10 */ "newvalue").Equals(!oldvalue!)
 		`)
 
 	efft.Note = "quote change"
-	efft.Effect(apply(8, "newvalue")).Equals(`
+	efft.Effect(apply(10, "newvalue")).Equals(`
 		 	efft.Effect("somevalue").Equals("somevalue")
 		 	efft.Effect("newvalue")
-		-	efft.Effect( /* line 8 */ "newvalue").Equals(!oldvalue!)
-		+	efft.Effect( /* line 8 */ "newvalue").Equals("newvalue")
-		 	efft.Effect("new\nvalue").Equals("oldvalue") // line 9
+		-	efft.Effect( /* line 10 */ "newvalue").Equals(!oldvalue!)
+		+	efft.Effect( /* line 10 */ "newvalue").Equals("newvalue")
+		 	efft.Effect("new\nvalue").Equals("oldvalue") // line 11
 		 	efft.Effect("new value").Equals(!
 		`)
 
 	efft.Note = "add newline"
-	efft.Effect(apply(8, "new\nvalue")).Equals(`
+	efft.Effect(apply(10, "new\nvalue")).Equals(`
 		 	efft.Effect("somevalue").Equals("somevalue")
 		 	efft.Effect("newvalue")
-		-	efft.Effect( /* line 8 */ "newvalue").Equals(!oldvalue!)
-		+	efft.Effect( /* line 8 */ "newvalue").Equals(!
+		-	efft.Effect( /* line 10 */ "newvalue").Equals(!oldvalue!)
+		+	efft.Effect( /* line 10 */ "newvalue").Equals(!
 		+		new
 		+		value!)
-		 	efft.Effect("new\nvalue").Equals("oldvalue") // line 9
+		 	efft.Effect("new\nvalue").Equals("oldvalue") // line 11
 		 	efft.Effect("new value").Equals(!
 		`)
 
 	efft.Note = "remove single internal newline"
-	efft.Effect(apply(10, "one\nthree\n")).Equals(`
+	efft.Effect(apply(12, "one\nthree\n")).Equals(`
 		 	efft.Effect("new value").Equals(!
 		 		one
 		-		two
 		-		three
-		-	!) // line 14
+		-	!) // line 16
 		+		three
 		+		!,
-		+	) // line 14
+		+	) // line 16
 		 	efft.Effect("\nnew\n\nvalue").Equals("oldvalue")
 		 	go func() {
 		`)
 
 	efft.Note = "remove two internal newlines"
-	efft.Effect(apply(10, "three\n")).Equals(`
-		 	efft.Effect("new\nvalue").Equals("oldvalue") // line 9
+	efft.Effect(apply(12, "three\n")).Equals(`
+		 	efft.Effect("new\nvalue").Equals("oldvalue") // line 11
 		 	efft.Effect("new value").Equals(!
 		-		one
 		-		two
 		-		three
-		-	!) // line 14
+		-	!) // line 16
 		+		three
 		+		!,
-		+	) // line 14
+		+	) // line 16
 		 	efft.Effect("\nnew\n\nvalue").Equals("oldvalue")
 		 	go func() {
 		`)
 
 	efft.Note = "remove last newline"
-	efft.Effect(apply(10, "one\ntwo\nthree")).Equals(`
+	efft.Effect(apply(12, "one\ntwo\nthree")).Equals(`
 		 		one
 		 		two
 		-		three
-		-	!) // line 14
+		-	!) // line 16
 		+		three!,
-		+	) // line 14
+		+	) // line 16
 		 	efft.Effect("\nnew\n\nvalue").Equals("oldvalue")
 		 	go func() {
 		`)
 
 	efft.Note = "remove all newlines"
-	efft.Effect(apply(10, "one two three")).Equals(`
-		 	efft.Effect( /* line 8 */ "newvalue").Equals(!oldvalue!)
-		 	efft.Effect("new\nvalue").Equals("oldvalue") // line 9
+	efft.Effect(apply(12, "one two three")).Equals(`
+		 	efft.Effect( /* line 10 */ "newvalue").Equals(!oldvalue!)
+		 	efft.Effect("new\nvalue").Equals("oldvalue") // line 11
 		-	efft.Effect("new value").Equals(!
 		-		one
 		-		two
 		-		three
-		-	!) // line 14
+		-	!) // line 16
 		+	efft.Effect("new value").Equals("one two three",
-		+	) // line 14
+		+	) // line 16
 		 	efft.Effect("\nnew\n\nvalue").Equals("oldvalue")
 		 	go func() {
 		`)
 
 	efft.Note = "add a newline"
-	efft.Effect(apply(10, "one\ntwo\nnewline\nthree\n")).Equals(`
+	efft.Effect(apply(12, "one\ntwo\nnewline\nthree\n")).Equals(`
 		 		one
 		 		two
 		-		three
-		-	!) // line 14
+		-	!) // line 16
 		+		newline
 		+		three
-		+		!) // line 14
+		+		!) // line 16
 		 	efft.Effect("\nnew\n\nvalue").Equals("oldvalue")
 		 	go func() {
 		`)
 
 	efft.Note = "update in goroutine"
-	efft.Effect(apply(17, "newvalue")).Equals(`
+	efft.Effect(apply(19, "newvalue")).Equals(`
 		 	efft.Effect("\nnew\n\nvalue").Equals("oldvalue")
 		 	go func() {
-		-		efft.Effect("newvalue").Equals("oldvalue") // line 17
-		+		efft.Effect("newvalue").Equals("newvalue") // line 17
+		-		efft.Effect("newvalue").Equals("oldvalue") // line 19
+		+		efft.Effect("newvalue").Equals("newvalue") // line 19
 		 	}()
 		 	efft.Effect("a", "b", "c").Equals("oldvalue")
 		`)
 
 	efft.Note = "expect has multiple arguments"
-	efft.Effect(apply(19, "a,b,c")).Equals(`
-		 		efft.Effect("newvalue").Equals("oldvalue") // line 17
+	efft.Effect(apply(21, "a,b,c")).Equals(`
+		 		efft.Effect("newvalue").Equals("oldvalue") // line 19
 		 	}()
 		-	efft.Effect("a", "b", "c").Equals("oldvalue")
 		+	efft.Effect("a", "b", "c").Equals("a,b,c")
@@ -291,7 +1487,7 @@ func TestReplacer(t *testing.T) {
 		`)
 
 	efft.Note = "expectation is empty"
-	efft.Effect(apply(20, "a,b,c")).Equals(`
+	efft.Effect(apply(22, "a,b,c")).Equals(`
 		 	}()
 		 	efft.Effect("a", "b", "c").Equals("oldvalue")
 		-	efft.Effect("a", "b", "c").Equals()
@@ -301,7 +1497,7 @@ func TestReplacer(t *testing.T) {
 		`)
 
 	efft.Note = "expectation has multiple arguments"
-	efft.Effect(apply(21, "a,b,c")).Equals(`
+	efft.Effect(apply(23, "a,b,c")).Equals(`
 		 	efft.Effect("a", "b", "c").Equals("oldvalue")
 		 	efft.Effect("a", "b", "c").Equals()
 		-	efft.Effect("a", "b", "c").Equals("a", "b")
@@ -311,31 +1507,31 @@ func TestReplacer(t *testing.T) {
 		`)
 
 	efft.Note = "expectation is a number"
-	efft.Effect(apply(22, "a,b,c")).Equals(`
+	efft.Effect(apply(24, "a,b,c")).Equals(`
 		 	efft.Effect("a", "b", "c").Equals()
 		 	efft.Effect("a", "b", "c").Equals("a", "b")
 		-	efft.Effect("a", "b", "c").Equals(3)
 		+	efft.Effect("a", "b", "c").Equals("a,b,c")
 		 	// some comment before
-		 	efft.Effect("y\nx").Equals("x\ny") // line 24
+		 	efft.Effect("y\nx").Equals("x\ny") // line 26
 		`)
 
 	efft.Note = "adding expectation keeps the post-comment intact"
-	efft.Effect(apply(24, "x\ny")).Equals(`
+	efft.Effect(apply(26, "x\ny")).Equals(`
 		 	efft.Effect("a", "b", "c").Equals(3)
 		 	// some comment before
-		-	efft.Effect("y\nx").Equals("x\ny") // line 24
+		-	efft.Effect("y\nx").Equals("x\ny") // line 26
 		+	efft.Effect("y\nx").Equals(!
 		+		x
-		+		y!) // line 24
+		+		y!) // line 26
 		 	efft.Effect("y\nx").Equals("x\ny")
 		 	// some comment after
 		`)
 
 	efft.Note = "adding expectation keeps the next comment intact"
-	efft.Effect(apply(25, "x\ny")).Equals(`
+	efft.Effect(apply(27, "x\ny")).Equals(`
 		 	// some comment before
-		 	efft.Effect("y\nx").Equals("x\ny") // line 24
+		 	efft.Effect("y\nx").Equals("x\ny") // line 26
 		-	efft.Effect("y\nx").Equals("x\ny")
 		+	efft.Effect("y\nx").Equals(!
 		+		x
@@ -345,19 +1541,488 @@ func TestReplacer(t *testing.T) {
 		`)
 
 	efft.Note = "backtick in the string means quoted string"
-	efft.Effect(apply(6, "x\n`\ny")).Equals(`
+	efft.Effect(apply(8, "x\n`\ny")).Equals(`
 		 	efft.Init(t)
-		 	// line 5
+		 	// line 7
 		-	efft.Effect("somevalue").Equals("somevalue")
 		+	efft.Effect("somevalue").Equals("x\n!\ny")
 		 	efft.Effect("newvalue")
-		 	efft.Effect( /* line 8 */ "newvalue").Equals(!oldvalue!)
+		 	efft.Effect( /* line 10 */ "newvalue").Equals(!oldvalue!)
 		`)
 
 	efft.Note = "bad replacement"
 	efft.Effect(apply(1, "")).Equals("efft.ReplacementsFailed file=test.go lines=[1]")
 }
 
+func TestEffects(t *testing.T) {
+	efft.Init(t)
+	tmpfile := filepath.Join(t.TempDir(), "test.go")
+	efft.Must(os.WriteFile(tmpfile, []byte(internal.Detab(`
+		package main
+
+		import e "github.com/ypsu/efftesting/efft"
+
+		func TestSomething() {
+			e.Effect("a")
+			e.FatalEffect("b").Equals("b")
+			if false {
+				e.Effect("c").Equals("c")
+			}
+			e.Stringify("d")
+			x.Effect("e").Equals("e").Equals("f")
+			x.Effective()
+			efft.Effect("g").Equals("g")
+			e.EffectPanic(nil).Equals("")
+		}
+		`)), 0644))
+	locs, err := internal.Effects(tmpfile)
+	efft.Must(err)
+	efft.Effect(strings.ReplaceAll(fmt.Sprint(locs), tmpfile, "test.go")).Equals("[test.go:6 test.go:7 test.go:9 test.go:15]")
+
+	efft.Must(os.WriteFile(tmpfile, []byte("package main\nfunc TestOther() { efft.Effect(1).Equals(1) }\n"), 0644))
+	efft.Effect(internal.Effects(tmpfile)).Equals("null")
+
+	_, err = internal.Effects(filepath.Join(t.TempDir(), "missing.go"))
+	efft.Effect(err != nil).Equals("true")
+}
+
+func TestMigrate(t *testing.T) {
+	efft.Init(t)
+	testfile := internal.Detab(`
+		package main
+
+		import (
+			"fmt"
+			"testing"
+
+			"github.com/stretchr/testify/assert"
+			req "github.com/stretchr/testify/require"
+		)
+
+		func TestSomething(t *testing.T) {
+			got, err := compute()
+			req.NoError(t, err)
+			want := 42
+			assert.Equal(t, want, got, "compute result")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got2 := got + 1; got2 != 43 {
+				t.Errorf("got %d", got2)
+			}
+			if s := fmt.Sprint(got); s != "42" {
+				t.Errorf("got %q", s)
+			}
+			for _, x := range []int{1, 2} {
+				assert.Equal(t, x, x)
+			}
+		}
+
+		func TestKinds(t *testing.T) {
+			got := compute()
+			req.Equal(t, 42, got)
+			assert.NoError(t, check(got))
+			assert.Truef(t, got > 0, "got=%d", got)
+			if got := compute2(); got != 7 {
+				t.Fatalf("got %d", got)
+			}
+			if got > 100 {
+				t.Log("big")
+			} else if got != 42 {
+				t.Error("not 42")
+			}
+		}
+
+		func TestExpected(t *testing.T) {
+			expected := compute2()
+			if expected != compute() {
+				t.Errorf("mismatch")
+			}
+		}
+
+		func TestParallel(t *testing.T) {
+			t.Parallel()
+			assert.True(t, true)
+		}
+
+		func helper(t *testing.T) {
+			assert.True(t, true)
+		}
+		`)
+	newfile, cnt, err := internal.Migrate("test.go", []byte(testfile))
+	efft.Must(err)
+	efft.Effect(cnt).Equals("11")
+	efft.Effect(efft.Diff(testfile, string(newfile))).Equals(`
+		 
+		 	"github.com/stretchr/testify/assert"
+		-	req "github.com/stretchr/testify/require"
+		-)
+		-
+		-func TestSomething(t *testing.T) {
+		-	got, err := compute()
+		-	req.NoError(t, err)
+		-	want := 42
+		-	assert.Equal(t, want, got, "compute result")
+		-	if err != nil {
+		-		t.Fatal(err)
+		-	}
+		-	if got2 := got + 1; got2 != 43 {
+		-		t.Errorf("got %d", got2)
+		-	}
+		-	if s := fmt.Sprint(got); s != "42" {
+		-		t.Errorf("got %q", s)
+		-	}
+		-	for _, x := range []int{1, 2} {
+		-		assert.Equal(t, x, x)
+		-	}
+		-}
+		-
+		-func TestKinds(t *testing.T) {
+		-	got := compute()
+		-	req.Equal(t, 42, got)
+		-	assert.NoError(t, check(got))
+		-	assert.Truef(t, got > 0, "got=%d", got)
+		-	if got := compute2(); got != 7 {
+		-		t.Fatalf("got %d", got)
+		-	}
+		-	if got > 100 {
+		-		t.Log("big")
+		-	} else if got != 42 {
+		-		t.Error("not 42")
+		-	}
+		-}
+		-
+		-func TestExpected(t *testing.T) {
+		-	expected := compute2()
+		-	if expected != compute() {
+		-		t.Errorf("mismatch")
+		-	}
+		+	"github.com/ypsu/efftesting/efft"
+		+)
+		+
+		+func TestSomething(t *testing.T) {
+		+	efft.Init(t)
+		+	got, err := compute()
+		+	efft.Must(err)
+		+
+		+	efft.Note = "compute result"
+		+	efft.Effect(got)
+		+	efft.Note = ""
+		+	efft.Must(err)
+		+	got2 := got + 1
+		+	efft.Effect(got2)
+		+	s := fmt.Sprint(got)
+		+	efft.Effect(s)
+		+	for _, x := range []int{1, 2} {
+		+		assert.Equal(t, x, x)
+		+	}
+		+}
+		+
+		+func TestKinds(t *testing.T) {
+		+	efft.Init(t)
+		+	got := compute()
+		+	efft.FatalEffect(got)
+		+	efft.Effect(check(got))
+		+	efft.Note = fmt.Sprintf("got=%d", got)
+		+	efft.Effect(got > 0)
+		+	{
+		+		got := compute2()
+		+		efft.Note = ""
+		+		efft.FatalEffect(got)
+		+	}
+		+	if got > 100 {
+		+		t.Log("big")
+		+	} else {
+		+		efft.Effect(got)
+		+	}
+		+}
+		+
+		+func TestExpected(t *testing.T) {
+		+	efft.Init(t)
+		+	_ = compute2()
+		+	efft.Effect(compute())
+		 }
+		 
+		`)
+
+	_, _, err = internal.Migrate("test.go", []byte("package"))
+	efft.Effect(err).Equals("efft.ParseSource: test.go:1:8: expected 'IDENT', found 'EOF'")
+}
+
+func TestReviewer(t *testing.T) {
+	efft.Init(t)
+	out := &strings.Builder{}
+	reviewer := &internal.Reviewer{In: bufio.NewReader(strings.NewReader("x\na\ns\nA\n")), Out: out}
+	review := func(line int) bool {
+		return reviewer.Review(internal.Location{Fname: "/a_test.go", Line: line}, "", "-old\n+new\n")
+	}
+	efft.Effect([]bool{review(1), review(2), review(3), review(4)}).Equals(`
+		[
+		  true,
+		  false,
+		  true,
+		  true
+		]`)
+	efft.Effect(out).Equals(`
+		efft.Review /a_test.go:1 -expectation +runtime:
+		-old
+		+new
+		accept? [a]ccept, [s]kip, [A]ccept all, [q]uit: accept? [a]ccept, [s]kip, [A]ccept all, [q]uit: efft.Review /a_test.go:2 -expectation +runtime:
+		-old
+		+new
+		accept? [a]ccept, [s]kip, [A]ccept all, [q]uit: efft.Review /a_test.go:3 -expectation +runtime:
+		-old
+		+new
+		accept? [a]ccept, [s]kip, [A]ccept all, [q]uit: `)
+
+	efft.Note = "quit rejects the rest"
+	out.Reset()
+	reviewer = &internal.Reviewer{In: bufio.NewReader(strings.NewReader("q\n")), Out: out}
+	efft.Effect([]bool{reviewer.Review(internal.Location{Fname: "/b_test.go", Line: 5}, "somenote", ""), review(1)}).Equals(`
+		[
+		  false,
+		  false
+		]`)
+	efft.Effect(strings.ReplaceAll(out.String(), "`", "!")).Equals(`
+		efft.Review /b_test.go:5 note=!somenote! -expectation +runtime:
+		accept? [a]ccept, [s]kip, [A]ccept all, [q]uit: `)
+}
+
+// TestReviewCommand runs `efft review` on a throwaway module with the answers from a file instead of the terminal.
+// tempModule writes files into a temporary module that requires this efftesting tree and returns its directory.
+func tempModule(t *testing.T, files map[string]string) string {
+	root := efft.Must1(filepath.Abs(".."))
+	dir := t.TempDir()
+	gomod := "module tmpmod\n\ngo 1.23.0\n\nrequire github.com/ypsu/efftesting v0.0.0\n\nreplace github.com/ypsu/efftesting => " + root + "\n"
+	efft.Must(os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0644))
+	efft.Must(os.WriteFile(filepath.Join(dir, "go.sum"), efft.Must1(os.ReadFile(filepath.Join(root, "go.sum"))), 0644))
+	for name, content := range files {
+		efft.Must(os.WriteFile(filepath.Join(dir, name), []byte(internal.Detab(content)), 0644))
+	}
+	return dir
+}
+
+// goCmd returns a go command that runs in dir without the EFF* envvars of the test, env is added on top.
+func goCmd(dir string, env []string, args ...string) *exec.Cmd {
+	cmd := exec.Command("go", args...)
+	cmd.Dir, cmd.Env = dir, append(env, "GOFLAGS=-mod=mod")
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, "EFF") && !strings.HasPrefix(env, "GOFLAGS=") {
+			cmd.Env = append(cmd.Env, env)
+		}
+	}
+	return cmd
+}
+
+// durationRE matches the test durations in the go test output.
+var durationRE = regexp.MustCompile(`[0-9.]+s\b`)
+
+func TestReviewCommand(t *testing.T) {
+	efft.Init(t)
+	dir := tempModule(t, map[string]string{
+		"a_test.go": `
+			package tmpmod
+
+			import (
+				"testing"
+
+				"github.com/ypsu/efftesting/efft"
+			)
+
+			func TestAsk(t *testing.T) {
+				efft.Init(t)
+				efft.Effect(1).Equals("2")
+				efft.Effect(3).Equals("4")
+				efft.Effect(5)
+			}
+			`,
+		"answers": "a\ns\na\n",
+	})
+	testfile := filepath.Join(dir, "a_test.go")
+	cmd := goCmd(dir, []string{"EFFTESTING_TTY=" + filepath.Join(dir, "answers")}, "run", "github.com/ypsu/efftesting/efft/cmd/efft", "review", "-run=TestAsk")
+	output, err := cmd.CombinedOutput()
+	efft.Scrub(dir, "$DIR")
+	efft.Effect(durationRE.ReplaceAllString(string(output), "1.0s")).Equals(`
+		efft.Review $DIR/a_test.go:11 -expectation +runtime:
+		-2
+		+1
+		accept? [a]ccept, [s]kip, [A]ccept all, [q]uit: efft.Review $DIR/a_test.go:12 -expectation +runtime:
+		-4
+		+3
+		accept? [a]ccept, [s]kip, [A]ccept all, [q]uit: efft.Review $DIR/a_test.go:13 -expectation +runtime:
+		+5
+		accept? [a]ccept, [s]kip, [A]ccept all, [q]uit: --- FAIL: TestAsk (1.0s)
+		    a_test.go:11: efft.EffectDiff -expectation +runtime:
+		        -2
+		        +1
+		    a_test.go:12: efft.EffectDiff -expectation +runtime:
+		        -4
+		        +3
+		    a_test.go:10: efft.IncompleteExpectations: will update them at end
+		    a_test.go:10: efft.WrongExpectations: will update 1 of them at end, rejected 1 in the review
+		FAIL
+		exit status 1
+		FAIL	tmpmod	1.0s
+		efft.ExpectationsUpdatedSuccessfully
+		efft.GoTest: exit status 1
+		exit status 1
+		`)
+	efft.Effect(err).Equals("exit status 1")
+	efft.Effect(efft.Must1(os.ReadFile(testfile))).Equals(`
+		package tmpmod
+
+		import (
+			"testing"
+
+			"github.com/ypsu/efftesting/efft"
+		)
+
+		func TestAsk(t *testing.T) {
+			efft.Init(t)
+			efft.Effect(1).Equals("1")
+			efft.Effect(3).Equals("4")
+			efft.Effect(5).Equals("5")
+		}
+		`)
+}
+
+// TestReportApply checks the EFFREPORT records and that `efft apply` applies them.
+func TestReportApply(t *testing.T) {
+	efft.Init(t)
+	dir := tempModule(t, map[string]string{
+		"a_test.go": `
+			package tmpmod
+
+			import (
+				"testing"
+
+				"github.com/ypsu/efftesting/efft"
+			)
+
+			func TestReport(t *testing.T) {
+				efft.Init(t)
+				efft.Effect(1).Equals("1")
+				efft.Note = "some note"
+				efft.Effect(2).Equals("3")
+				efft.Note = ""
+				efft.Effect(4)
+				efft.FatalEffect(5).Equals("6")
+				efft.Effect(7).Equals("8")
+			}
+			`,
+	})
+	efft.Scrub(dir, "$DIR")
+	report := filepath.Join(dir, "report.json")
+	_, err := goCmd(dir, []string{"EFFREPORT=" + report}, "test", "-count=1", ".").CombinedOutput()
+	efft.Effect(err).Equals("exit status 1")
+	efft.Effect(efft.Must1(os.ReadFile(report))).Equals(`
+		{"file":"$DIR/a_test.go","line":11,"test":"TestReport","status":"ok","expected":"1","got":"1","fatal":false}
+		{"file":"$DIR/a_test.go","line":13,"test":"TestReport","status":"wrong","expected":"3","got":"2","note":"some note","fatal":false}
+		{"file":"$DIR/a_test.go","line":16,"test":"TestReport","status":"wrong","expected":"6","got":"5","fatal":true}
+		{"file":"$DIR/a_test.go","line":15,"test":"TestReport","status":"incomplete","got":"4","fatal":false}
+		`)
+
+	output, err := goCmd(dir, nil, "run", "github.com/ypsu/efftesting/efft/cmd/efft", "apply", report).CombinedOutput()
+	efft.Effect(output, err).Equals("")
+	efft.Effect(efft.Must1(os.ReadFile(filepath.Join(dir, "a_test.go")))).Equals(`
+		package tmpmod
+
+		import (
+			"testing"
+
+			"github.com/ypsu/efftesting/efft"
+		)
+
+		func TestReport(t *testing.T) {
+			efft.Init(t)
+			efft.Effect(1).Equals("1")
+			efft.Note = "some note"
+			efft.Effect(2).Equals("2")
+			efft.Note = ""
+			efft.Effect(4).Equals("4")
+			efft.FatalEffect(5).Equals("5")
+			efft.Effect(7).Equals("8")
+		}
+		`)
+}
+
+// TestStaleEffects checks the EFFSTALE listing of efft.Main.
+func TestStaleEffects(t *testing.T) {
+	efft.Init(t)
+	dir := tempModule(t, map[string]string{
+		"a_test.go": `
+			package tmpmod
+
+			import (
+				"testing"
+
+				"github.com/ypsu/efftesting/efft"
+			)
+
+			func TestMain(m *testing.M) { efft.Main(m) }
+
+			func TestRun(t *testing.T) {
+				efft.Init(t)
+				efft.Effect(1).Equals("1")
+			}
+
+			func TestFiltered(t *testing.T) {
+				efft.Init(t)
+				efft.Effect(2).Equals("2")
+				efft.EffectPanic(func() {}).Equals("null")
+			}
+			`,
+	})
+	efft.Scrub(dir, "$DIR")
+	// The package is tested in the local directory mode so that go test prints the output of the passing runs too.
+	run := func(stale string) string {
+		output, err := goCmd(dir, []string{"EFFSTALE=" + stale}, "test", "-count=1", "-run=TestRun").CombinedOutput()
+		return fmt.Sprintf("%s(err=%v)", durationRE.ReplaceAllString(string(output), "1.0s"), err)
+	}
+	efft.Effect(run("")).Equals(`
+		PASS
+		ok  	tmpmod	1.0s
+		(err=<nil>)`)
+	efft.Effect(run("1")).Equals(`
+		PASS
+		efft.StaleEffect $DIR/a_test.go:18
+		efft.StaleEffect $DIR/a_test.go:19
+		ok  	tmpmod	1.0s
+		(err=<nil>)`)
+	efft.Effect(run("fail")).Equals(`
+		PASS
+		efft.StaleEffect $DIR/a_test.go:18
+		efft.StaleEffect $DIR/a_test.go:19
+		exit status 1
+		FAIL	tmpmod	1.0s
+		(err=exit status 1)`)
+}
+
+func TestReadReport(t *testing.T) {
+	efft.Init(t)
+	report := internal.Detab(`
+		{"file":"/a_test.go","line":3,"test":"TestA","status":"wrong","expected":"1","got":"2","fatal":false}
+		{"file":"/a_test.go","line":4,"test":"TestA","status":"incomplete","got":"3","fatal":true}
+		{"file":"/a_test.go","line":5,"test":"TestA","status":"wrong","expected":"1","got":"2","fatal":false}
+		{"file":"/a_test.go","line":5,"test":"TestA","status":"ok","expected":"2","got":"2","fatal":false}
+		{"file":"/a_test.go","line":6,"test":"TestA","status":"ok","expected":"2","got":"2","fatal":false}
+		{"file":"","line":0,"test":"TestA","status":"wrong","expected":"1","got":"2","fatal":false}`)
+	readReport := func(report string) (map[string]string, error) {
+		replacements, err := internal.ReadReport(strings.NewReader(report))
+		m := map[string]string{}
+		for loc, newstr := range replacements {
+			m[loc.String()] = newstr
+		}
+		return m, err
+	}
+	efft.Effect(readReport(report)).Equals(`
+		{
+		  "/a_test.go:3": "2",
+		  "/a_test.go:4": "3"
+		}`)
+	efft.Effect(readReport("{}\nnot json\n")).Equals("efft.ParseRecord line=2: invalid character 'o' in literal null (expecting 'u')")
+}
+
 func TestMust(t *testing.T) {
 	efft.Init(t)
 	efft.Must(true)
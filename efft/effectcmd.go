@@ -0,0 +1,50 @@
+package efft
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// tempDirRE matches the t.TempDir() style paths: the test's directory with a random suffix and a sequence number.
+var tempDirRE = regexp.MustCompile(regexp.QuoteMeta(filepath.Clean(os.TempDir())) + `/[^/\s]*?[0-9]+/([0-9]{3})\b`)
+
+// EffectCmd runs cmd and sets up an expectation for its exit code and output in the RunResult format.
+// If cmd.Env is nil then the command runs in a scrubbed environment:
+// only PATH is kept, HOME points to an empty temporary directory, the locale is C and the timezone is UTC.
+// The output is scrubbed too: cmd.Dir becomes $WORK, the HOME becomes $HOME, the t.TempDir() paths become $TMPDIR/001 style paths.
+// The Scrub replacements apply on top of these.
+func EffectCmd(cmd *exec.Cmd) result { //revive:disable-line:unexported-return
+	checkT()
+	t.Helper()
+	got := scrub(runCmd(cmd).String())
+	return track(result{got, defaultReplacer.Replace(got), false, nil, false})
+}
+
+func runCmd(cmd *exec.Cmd) RunResult {
+	var pairs []string
+	if cmd.Dir != "" {
+		pairs = append(pairs, cmd.Dir, "$WORK")
+	}
+	if cmd.Env == nil {
+		home := t.TempDir()
+		pairs = append(pairs, home, "$HOME")
+		cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "HOME=" + home, "LC_ALL=C", "TZ=UTC"}
+	}
+	r, err := runResult(cmd)
+	if err != nil {
+		t.Fatalf("efft.RunCommand: %v", err)
+	}
+	r.Stdout, r.Stderr = scrubTemp(r.Stdout, pairs), scrubTemp(r.Stderr, pairs)
+	return r
+}
+
+// scrubTemp applies the replacement pairs and then scrubs the remaining temporary directory paths.
+func scrubTemp(s string, pairs []string) string {
+	for i := 0; i < len(pairs); i += 2 {
+		s = strings.ReplaceAll(s, pairs[i], pairs[i+1])
+	}
+	return tempDirRE.ReplaceAllString(s, "$$TMPDIR/$1")
+}
@@ -0,0 +1,263 @@
+// Package efftvet contains an analyzer that reports efft misuses that would otherwise only show up at runtime.
+// Run it via `go vet -vettool=$(which efftvet) ./...` after `go install github.com/ypsu/efftesting/efft/cmd/efftvet`.
+//
+// It reports these problems:
+//
+//   - efft.Effect without an Equals: run the tests with EFFUP=1 to fill in the expectation.
+//   - efft.Effect that is not used as a `efft.Effect(...).Equals(...)` statement: the rewriter can't update such expectations.
+//   - Equals with a non-literal argument such as a constant or a concatenation: the rewriter would replace it.
+//   - efft.FatalEffect in a goroutine: t.Fatal must be called from the test's goroutine.
+//   - a test that uses efft without calling efft.Init(t) first.
+//   - efft.Init in a parallel test: efft doesn't support them.
+package efftvet
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const efftPath = "github.com/ypsu/efftesting/efft"
+
+// Analyzer reports efft misuses.
+var Analyzer = &analysis.Analyzer{
+	Name:     "efftvet",
+	Doc:      "report efft misuses such as missing efft.Init calls or non-literal expectations",
+	URL:      "https://pkg.go.dev/github.com/ypsu/efftesting/efft/efftvet",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// needsInit is the set of efft functions that panic without an efft.Init call first.
+// TestNeedsInit keeps it in sync with the efft functions that call checkT.
+var needsInit = map[string]bool{
+	"Effect":       true,
+	"EffectCmd":    true,
+	"EffectDir":    true,
+	"EffectFS":     true,
+	"EffectGo":     true,
+	"EffectImage":  true,
+	"EffectOutput": true,
+	"EffectPanic":  true,
+	"FatalEffect":  true,
+	"Must":         true,
+	"Must1":        true,
+	"Must2":        true,
+	"Override":     true,
+	"RunMain":      true,
+	"Scrub":        true,
+}
+
+// efftFunc returns the name of the called efft function or method, or "" if the call is not an efft call.
+func efftFunc(pass *analysis.Pass, call *ast.CallExpr) string {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != efftPath {
+		return ""
+	}
+	return fn.Name()
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.WithStack([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		call := n.(*ast.CallExpr)
+		switch name := efftFunc(pass, call); {
+		case strings.HasPrefix(name, "Effect"):
+			checkEffect(pass, call, stack)
+		case name == "FatalEffect":
+			checkEffect(pass, call, stack)
+			checkGoroutine(pass, call, stack)
+		case name == "Equals":
+			checkEquals(pass, call)
+		}
+		return true
+	})
+	inspect.Preorder([]ast.Node{(*ast.FuncDecl)(nil)}, func(n ast.Node) {
+		checkTest(pass, n.(*ast.FuncDecl))
+	})
+	return nil, nil
+}
+
+// checkEffect reports effects that are not used as `efft.Effect(...).Equals(...)` statements.
+func checkEffect(pass *analysis.Pass, call *ast.CallExpr, stack []ast.Node) {
+	n := len(stack)
+	if n >= 2 {
+		if _, ok := stack[n-2].(*ast.ExprStmt); ok {
+			pass.Reportf(call.Pos(), "efft.MissingEquals: run the test with EFFUP=1 envvar to fill in the expectation")
+			return
+		}
+	}
+	if n >= 4 {
+		sel, selok := stack[n-2].(*ast.SelectorExpr)
+		_, stmtok := stack[n-4].(*ast.ExprStmt)
+		if selok && stmtok && sel.Sel.Name == "Equals" {
+			return
+		}
+	}
+	pass.Reportf(call.Pos(), "efft.UnrewritableEffect: use the effect as an `efft.Effect(...).Equals(...)` statement so that EFFUP=1 can update it")
+}
+
+// checkEquals reports expectations that are not string literals.
+func checkEquals(pass *analysis.Pass, call *ast.CallExpr) {
+	if len(call.Args) != 1 {
+		return
+	}
+	arg := call.Args[0]
+	if lit, ok := arg.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+		return
+	}
+	diag := analysis.Diagnostic{
+		Pos:     arg.Pos(),
+		End:     arg.End(),
+		Message: "efft.NonLiteralExpectation: EFFUP=1 would replace the expression, use a string literal instead",
+	}
+	if tv := pass.TypesInfo.Types[arg]; tv.Value != nil && tv.Value.Kind() == constant.String {
+		diag.SuggestedFixes = []analysis.SuggestedFix{{
+			Message:   "Inline the constant",
+			TextEdits: []analysis.TextEdit{{Pos: arg.Pos(), End: arg.End(), NewText: []byte(strconv.Quote(constant.StringVal(tv.Value)))}},
+		}}
+	}
+	pass.Report(diag)
+}
+
+// checkGoroutine reports FatalEffect calls within go statements.
+func checkGoroutine(pass *analysis.Pass, call *ast.CallExpr, stack []ast.Node) {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if _, ok := stack[i].(*ast.FuncDecl); ok {
+			return
+		}
+		if _, ok := stack[i].(*ast.GoStmt); !ok {
+			continue
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:     call.Pos(),
+			End:     call.End(),
+			Message: "efft.FatalEffectInGoroutine: FatalEffect can only abort the test from the test's goroutine",
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message:   "Use Effect instead",
+				TextEdits: []analysis.TextEdit{{Pos: sel.Sel.Pos(), End: sel.Sel.End(), NewText: []byte("Effect")}},
+			}},
+		})
+		return
+	}
+}
+
+// testParam returns the *testing.T parameter if fn is a test function.
+func testParam(pass *analysis.Pass, fn *ast.FuncDecl) *ast.Ident {
+	if !strings.HasPrefix(fn.Name.Name, "Test") || fn.Recv != nil || fn.Body == nil || len(fn.Type.Params.List) != 1 {
+		return nil
+	}
+	param := fn.Type.Params.List[0]
+	if len(param.Names) != 1 || types.TypeString(pass.TypesInfo.TypeOf(param.Type), nil) != "*testing.T" {
+		return nil
+	}
+	return param.Names[0]
+}
+
+// checkTest reports the missing or late efft.Init calls and the efft.Init calls in parallel tests.
+func checkTest(pass *analysis.Pass, fn *ast.FuncDecl) {
+	param := testParam(pass, fn)
+	if param == nil {
+		return
+	}
+	tname, tobj := param.Name, pass.TypesInfo.Defs[param]
+	var initCall, firstUse *ast.CallExpr
+	var initStmt, parallelStmt *ast.ExprStmt
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if stmt, ok := n.(*ast.ExprStmt); ok {
+			if call, ok := stmt.X.(*ast.CallExpr); ok {
+				// The subtests' t.Parallel() calls refer to their own t.
+				if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Parallel" {
+					if id, ok := sel.X.(*ast.Ident); ok && pass.TypesInfo.Uses[id] == tobj {
+						parallelStmt = stmt
+					}
+				}
+				if efftFunc(pass, call) == "Init" && initStmt == nil {
+					initStmt = stmt
+				}
+			}
+		}
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		switch name := efftFunc(pass, call); {
+		case name == "Init" && initCall == nil:
+			initCall = call
+		case needsInit[name] && firstUse == nil:
+			firstUse = call
+		}
+		return true
+	})
+	if initCall != nil && parallelStmt != nil {
+		pass.Report(analysis.Diagnostic{
+			Pos:     parallelStmt.Pos(),
+			End:     parallelStmt.End(),
+			Message: "efft.UnsupportedParallelTesting: efft doesn't support parallel tests",
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message:   "Remove the " + tname + ".Parallel() call",
+				TextEdits: []analysis.TextEdit{{Pos: parallelStmt.Pos(), End: parallelStmt.End()}},
+			}},
+		})
+	}
+	if firstUse == nil || len(fn.Body.List) == 0 || initCall != nil && initCall.Pos() < firstUse.Pos() {
+		return
+	}
+	pkgname := "efft"
+	if sel, ok := firstUse.Fun.(*ast.SelectorExpr); ok {
+		pkgname = types.ExprString(sel.X)
+	} else if idx, ok := firstUse.Fun.(*ast.IndexExpr); ok {
+		if sel, ok := idx.X.(*ast.SelectorExpr); ok {
+			pkgname = types.ExprString(sel.X)
+		}
+	}
+	first := fn.Body.List[0]
+	diag := analysis.Diagnostic{
+		Pos:     firstUse.Pos(),
+		End:     firstUse.End(),
+		Message: fmt.Sprintf("efft.MissingInit: call %s.Init(%s) at the beginning of %s", pkgname, tname, fn.Name.Name),
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message:   "Add the efft.Init call",
+			TextEdits: []analysis.TextEdit{{Pos: first.Pos(), End: first.Pos(), NewText: []byte(fmt.Sprintf("%s.Init(%s)\n\t", pkgname, tname))}},
+		}},
+	}
+	if initCall != nil {
+		diag.Message = fmt.Sprintf("efft.LateInit: call %s.Init(%s) at the beginning of %s, before the first efft use", pkgname, tname, fn.Name.Name)
+		if initStmt != nil && initStmt.X == initCall {
+			// A second Init call would fail the test so move the existing one.
+			// Remove the statement's line too if it's a top-level statement, gofmt puts those on their own lines.
+			start := initStmt.Pos()
+			if slices.Contains(fn.Body.List, ast.Stmt(initStmt)) {
+				tf := pass.Fset.File(start)
+				start = tf.LineStart(tf.Line(start)) - 1
+			}
+			diag.SuggestedFixes = []analysis.SuggestedFix{{
+				Message: "Move the efft.Init call to the beginning",
+				TextEdits: []analysis.TextEdit{
+					{Pos: first.Pos(), End: first.Pos(), NewText: []byte(types.ExprString(initCall) + "\n\t")},
+					{Pos: start, End: initStmt.End()},
+				},
+			}}
+		} else {
+			diag.SuggestedFixes = nil
+		}
+	}
+	pass.Report(diag)
+}
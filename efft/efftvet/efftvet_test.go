@@ -0,0 +1,83 @@
+package efftvet_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ypsu/efftesting/efft/efftvet"
+	"github.com/ypsu/efftesting/efft/internal"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), efftvet.Analyzer, "a")
+}
+
+// efftFuncs returns the sorted names of the exported efft functions for which match returns true.
+func efftFuncs(t *testing.T, match func(fn *ast.FuncDecl) bool) []string {
+	fnames, err := filepath.Glob("../*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	fset := token.NewFileSet()
+	for _, fname := range fnames {
+		if strings.HasSuffix(fname, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, fname, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.IsExported() && fn.Body != nil && match(fn) {
+				names = append(names, fn.Name.Name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		t.Fatal("efft.NoFunctionsFound")
+	}
+	slices.Sort(names)
+	return names
+}
+
+// TestNeedsInit checks that needsInit has exactly the exported efft functions that call checkT.
+func TestNeedsInit(t *testing.T) {
+	want := efftFuncs(t, func(fn *ast.FuncDecl) bool {
+		found := false
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "checkT" {
+					found = true
+				}
+			}
+			return !found
+		})
+		return found
+	})
+	if got := slices.Sorted(maps.Keys(efftvet.NeedsInit)); !slices.Equal(got, want) {
+		t.Errorf("efft.NeedsInitMismatch\n got: %v\nwant: %v", got, want)
+	}
+}
+
+// TestEffectFuncs checks that the rewriter knows all the exported efft functions that return an effect.
+func TestEffectFuncs(t *testing.T) {
+	want := efftFuncs(t, func(fn *ast.FuncDecl) bool {
+		results := fn.Type.Results
+		if results == nil || len(results.List) != 1 {
+			return false
+		}
+		id, ok := results.List[0].Type.(*ast.Ident)
+		return ok && id.Name == "result"
+	})
+	if got := slices.Sorted(maps.Keys(internal.EffectFuncs)); !slices.Equal(got, want) {
+		t.Errorf("efft.EffectFuncsMismatch\n got: %v\nwant: %v", got, want)
+	}
+}
@@ -0,0 +1,4 @@
+package efftvet
+
+// NeedsInit exports needsInit for the tests.
+var NeedsInit = needsInit
@@ -0,0 +1,64 @@
+package a
+
+import (
+	"testing"
+
+	"github.com/ypsu/efftesting/efft"
+)
+
+const expectation = "1"
+
+func TestGood(t *testing.T) {
+	efft.Init(t)
+	efft.Effect(1).Equals("1")
+	efft.FatalEffect(1).Equals(`1`)
+	efft.EffectPanic(func() {}).Equals("no panic")
+	efft.Must(nil)
+}
+
+func TestMisuse(t *testing.T) {
+	efft.Init(t)
+	efft.Effect(1)                                  // want `efft.MissingEquals: run the test with EFFUP=1 envvar to fill in the expectation`
+	r := efft.Effect(1)                             // want "efft.UnrewritableEffect"
+	r.Equals("1")                                   //
+	efft.Effect(1).Equals(expectation)              // want "efft.NonLiteralExpectation"
+	efft.Effect(1).Equals("1" + "")                 // want "efft.NonLiteralExpectation"
+	go func() { efft.FatalEffect(1).Equals("1") }() // want "efft.FatalEffectInGoroutine"
+}
+
+func TestMissingInitPanic(t *testing.T) {
+	efft.EffectPanic(func() {}) // want "efft.MissingInit" "efft.MissingEquals"
+}
+
+func TestMissingInit(t *testing.T) {
+	x := 1
+	efft.Override(&x, 2) // want `efft.MissingInit: call efft.Init\(t\) at the beginning of TestMissingInit`
+	efft.Effect(x).Equals("2")
+}
+
+func TestNoEfft(t *testing.T) {
+	t.Parallel()
+	efft.Stringify(1)
+}
+
+func TestParallel(t *testing.T) {
+	t.Parallel() // want "efft.UnsupportedParallelTesting"
+	efft.Init(t)
+	efft.Effect(1).Equals("1")
+}
+
+func helper() {
+	efft.Effect(1).Equals("1")
+}
+
+func TestLateInit(t *testing.T) {
+	efft.Effect(1).Equals("1") // want `efft.LateInit: call efft.Init\(t\) at the beginning of TestLateInit, before the first efft use`
+	efft.Init(t)
+}
+
+func TestParallelSubtest(t *testing.T) {
+	efft.Init(t)
+	t.Run("sub", func(t *testing.T) {
+		t.Parallel()
+	})
+}
@@ -0,0 +1,18 @@
+// Package efft is a stub of the real efft package for the analyzer tests.
+package efft
+
+import "testing"
+
+type expectationString string
+
+type result struct{}
+
+func (r result) Equals(wanted expectationString) {}
+
+func Init(t *testing.T)              {}
+func Effect(args ...any) result      { return result{} }
+func FatalEffect(args ...any) result { return result{} }
+func EffectPanic(fn any) result      { return result{} }
+func Must(err any)                   {}
+func Override[T any](p *T, v T)      {}
+func Stringify(args ...any) string   { return "" }
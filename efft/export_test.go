@@ -0,0 +1,14 @@
+package efft
+
+import "image"
+
+// FormatGo exposes formatGo for testing the invalid source errors.
+var FormatGo = formatGo
+
+// CompareImage exposes the mismatch description of compareImage.
+func CompareImage(img image.Image, path string) string {
+	return compareImage(img, path).mismatch
+}
+
+// WriteDiffImage exposes writeDiffImage for checking the diff image.
+var WriteDiffImage = writeDiffImage
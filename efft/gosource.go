@@ -0,0 +1,143 @@
+package efft
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// TypeCheckGo makes EffectGo type-check the source as a standalone package too.
+// The imports are resolved in the module of the test, so the source may import the module's own packages and its dependencies.
+var TypeCheckGo bool
+
+// EffectGo sets up an expectation for gofmt-formatted Go source, e.g. the output of a code generator.
+// The source that doesn't format or doesn't type-check with TypeCheckGo fails the test with the offending lines.
+// Then the expectation is neither checked nor updated.
+func EffectGo(src []byte) result { //revive:disable-line:unexported-return
+	checkT()
+	t.Helper()
+	got, err := formatGo(src)
+	if err != nil {
+		t.Errorf("efft.InvalidGo:\n%v", err)
+		loc := defaultReplacer.Replace("") // only marks the expectation as used for EFFSTALE
+		delete(defaultReplacer.Replacements, loc)
+		delete(defaultReplacer.Incomplete, loc)
+		return result{"", loc, false, nil, true}
+	}
+	got = scrub(got)
+	return track(result{got, defaultReplacer.Replace(got), false, nil, false})
+}
+
+// maxGoErrors is the maximum number of errors formatGo reports.
+const maxGoErrors = 10
+
+func formatGo(src []byte) (string, error) {
+	formatted, err := format.Source(src)
+	if err != nil {
+		var errs scanner.ErrorList
+		if !errors.As(err, &errs) {
+			return "", err
+		}
+		return "", goErrors(src, errs)
+	}
+	if !TypeCheckGo {
+		return string(formatted), nil
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", formatted, parser.ParseComments)
+	if err != nil {
+		// A declaration list or a statement list formats fine but it isn't a file.
+		return "", fmt.Errorf("efft.ParseFile: %v", err)
+	}
+	imp, err := moduleImporter(fset, f)
+	if err != nil {
+		return "", err
+	}
+	var errs scanner.ErrorList
+	cfg := &types.Config{
+		Importer: imp,
+		Error: func(err error) {
+			// The messages starting with a tab continue the previous error, e.g. "\tother declaration of X".
+			if e, ok := err.(types.Error); ok && !e.Soft && !strings.HasPrefix(e.Msg, "\t") {
+				errs.Add(e.Fset.Position(e.Pos), e.Msg)
+			}
+		},
+	}
+	cfg.Check(f.Name.Name, fset, []*ast.File{f}, nil)
+	if len(errs) > 0 {
+		// The type checker reports the package level errors before the function body errors.
+		errs.Sort()
+		return "", goErrors(formatted, errs)
+	}
+	return string(formatted), nil
+}
+
+// moduleImporter resolves the imports of f in the current directory's module.
+// go/packages finds the compiled export data, go/importer reads it so that it matches the toolchain's format.
+func moduleImporter(fset *token.FileSet, f *ast.File) (types.Importer, error) {
+	var paths []string
+	for _, imp := range f.Imports {
+		if path, err := strconv.Unquote(imp.Path.Value); err == nil && path != "unsafe" {
+			paths = append(paths, path)
+		}
+	}
+	pkgs := map[string]*packages.Package{}
+	if len(paths) > 0 {
+		mode := packages.NeedName | packages.NeedExportFile | packages.NeedImports | packages.NeedDeps
+		loaded, err := packages.Load(&packages.Config{Mode: mode}, paths...)
+		if err != nil {
+			return nil, fmt.Errorf("efft.LoadImports: %v", err)
+		}
+		packages.Visit(loaded, nil, func(pkg *packages.Package) { pkgs[pkg.PkgPath] = pkg })
+	}
+	lookup := func(path string) (io.ReadCloser, error) {
+		pkg := pkgs[path]
+		switch {
+		case pkg == nil:
+			return nil, fmt.Errorf("package %s not loaded", path)
+		case len(pkg.Errors) > 0:
+			return nil, errors.New(pkg.Errors[0].Msg)
+		}
+		return os.Open(pkg.ExportFile)
+	}
+	return importer.ForCompiler(fset, "gc", lookup), nil
+}
+
+// goErrors formats the errors with the source line they refer to and a caret under their column.
+func goErrors(src []byte, errs scanner.ErrorList) error {
+	lines := strings.Split(string(src), "\n")
+	sb := &strings.Builder{}
+	for i, e := range errs {
+		if i == maxGoErrors {
+			fmt.Fprintf(sb, "(%d more errors)\n", len(errs)-maxGoErrors)
+			break
+		}
+		pos := e.Pos
+		fmt.Fprintf(sb, "line=%d col=%d: %s\n", pos.Line, pos.Column, e.Msg)
+		if pos.Line < 1 || pos.Line > len(lines) {
+			continue
+		}
+		line := lines[pos.Line-1]
+		caret := []byte(line[:min(max(pos.Column-1, 0), len(line))])
+		for j, c := range caret {
+			if c != '\t' {
+				caret[j] = ' '
+			}
+		}
+		fmt.Fprintf(sb, "\t%s\n\t%s^\n", line, caret)
+	}
+	return errors.New(strings.TrimSuffix(sb.String(), "\n"))
+}
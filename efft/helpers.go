@@ -1,8 +1,13 @@
 package efft
 
 import (
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 )
 
 // Override overrides `p` for the duration of the test.
@@ -16,11 +21,36 @@ func Override[T any](p *T, v T) {
 	t.Cleanup(func() { *p = oldv })
 }
 
+var (
+	scrubPairs []string
+	scrubber   *strings.Replacer
+)
+
+// Scrub replaces from with to in the stringified effects for the rest of the test.
+// Use it to hide the volatile parts of the results, e.g. `efft.Scrub(t.TempDir(), "$TMP")`.
+// This is a convenience helper.
+func Scrub(from, to string) {
+	checkT()
+	scrubPairs = append(scrubPairs, from, to)
+	scrubber = strings.NewReplacer(scrubPairs...)
+}
+
+func scrub(s string) string {
+	if scrubber == nil {
+		return s
+	}
+	return scrubber.Replace(s)
+}
+
 // Must fails the current test if err is `false` or is a non-nil error.
 // This is a convenience helper.
 func Must(err any) {
 	checkT()
 	t.Helper()
+	if runMainTarget != 0 {
+		// The RunMain subprocess replays the test only to reach its RunMain call, the results before it are empty.
+		return
+	}
 	if v, ok := err.(bool); ok {
 		if !v {
 			t.Fatal("efft.UnexpectedFailure")
@@ -60,7 +90,21 @@ func stringify1(v any) string {
 		return s.Error()
 	}
 	switch v := v.(type) {
+	case *http.Request:
+		return stringifyRequest(v)
+	case *http.Response:
+		return stringifyResponse(v)
+	case *httptest.ResponseRecorder:
+		return stringifyRecorder(v)
+	case http.Header:
+		return stringifyHeader(v)
+	case *sql.Rows:
+		return stringifyRows(v)
 	case []byte:
+		if !isText(v) {
+			// The hexdump has the offset on each line so the diffs are easy to follow.
+			return strings.TrimSuffix(hex.Dump(v), "\n")
+		}
 		return string(v)
 	case string:
 		return v
@@ -68,6 +112,18 @@ func stringify1(v any) string {
 		return fmt.Sprint(v)
 	}
 
+	if s, ok := autoTable(v); ok {
+		return s
+	}
+	if CompactWidth > 0 {
+		js, err := json.Marshal(v)
+		if err != nil {
+			return err.Error()
+		}
+		if s, err := compactJSON(js); err == nil {
+			return s
+		}
+	}
 	js, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
 		return err.Error()
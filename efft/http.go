@@ -0,0 +1,99 @@
+package efft
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+)
+
+// VolatileHeaders are the HTTP headers whose values are replaced with "[scrubbed]" in the stringified HTTP messages.
+var VolatileHeaders = []string{"Date"}
+
+// stringifyRequest renders r in the HTTP/1.1 wire format.
+// The body is restored after reading so r remains usable.
+func stringifyRequest(r *http.Request) string {
+	method := cmp.Or(r.Method, http.MethodGet)
+	uri := r.RequestURI
+	if r.URL != nil {
+		uri = r.URL.RequestURI()
+	}
+	host := r.Host
+	if host == "" && r.URL != nil {
+		host = r.URL.Host
+	}
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "%s %s HTTP/1.1\n", method, uri)
+	if host != "" {
+		fmt.Fprintf(sb, "Host: %s\n", host)
+	}
+	writeHeader(sb, r.Header)
+	r.Body = writeBody(sb, r.Body, true)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// stringifyResponse renders r in the HTTP/1.1 wire format.
+// The body is restored after reading so r remains usable.
+func stringifyResponse(r *http.Response) string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "HTTP/1.1 %d %s\n", r.StatusCode, http.StatusText(r.StatusCode))
+	writeHeader(sb, r.Header)
+	r.Body = writeBody(sb, r.Body, true)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// stringifyRecorder renders the recorded response in the HTTP/1.1 wire format.
+func stringifyRecorder(r *httptest.ResponseRecorder) string {
+	var body io.ReadCloser
+	if r.Body != nil {
+		body = io.NopCloser(bytes.NewReader(r.Body.Bytes()))
+	}
+	return stringifyResponse(&http.Response{StatusCode: r.Code, Header: r.Header(), Body: body})
+}
+
+// stringifyHeader renders h as sorted `Key: value` lines.
+func stringifyHeader(h http.Header) string {
+	sb := &strings.Builder{}
+	writeHeader(sb, h)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func writeHeader(sb *strings.Builder, h http.Header) {
+	for _, key := range slices.Sorted(maps.Keys(h)) {
+		for _, value := range h[key] {
+			if slices.Contains(VolatileHeaders, http.CanonicalHeaderKey(key)) {
+				value = "[scrubbed]"
+			}
+			fmt.Fprintf(sb, "%s: %s\n", key, value)
+		}
+	}
+}
+
+// writeBody writes the body after an empty line if it's not empty and returns a replacement for the consumed body.
+// A JSON body is indented if indentJSON is set.
+func writeBody(sb *strings.Builder, body io.ReadCloser, indentJSON bool) io.ReadCloser {
+	if body == nil || body == http.NoBody {
+		return body
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		fmt.Fprintf(sb, "\nefft.ReadBody: %v\n", err)
+	}
+	if len(data) > 0 {
+		sb.WriteString("\n")
+		indented := &bytes.Buffer{}
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && indentJSON && (trimmed[0] == '{' || trimmed[0] == '[') && json.Indent(indented, trimmed, "", "  ") == nil {
+			sb.Write(indented.Bytes())
+		} else {
+			sb.Write(data)
+		}
+	}
+	return io.NopCloser(bytes.NewReader(data))
+}
@@ -0,0 +1,194 @@
+package efft
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/ypsu/efftesting/efft/internal"
+)
+
+// ImageTolerance is the largest difference per color channel on the 0-255 scale that EffectImage ignores.
+// Use it for rendering code with small platform-dependent differences, e.g. in antialiasing.
+var ImageTolerance int
+
+// imageCalls is the number of EffectImage calls in the current test.
+var imageCalls int
+
+// EffectImage sets up an expectation for img against a golden PNG file.
+// The golden file is testdata/<TestName>.png for the first call in a test, testdata/<TestName>_<n>.png for the nth one.
+// The inline expectation is the size and the SHA-256 hash of the golden image's pixels.
+// The pixels may differ from the golden ones by ImageTolerance, then the expectation is still the golden image's.
+//
+// On a mismatch EffectImage writes a diff image into a temp file and reports it with the number and bounding box of the changed pixels.
+// The changed pixels are red on the diff image, the rest are the faded golden pixels.
+// With EFFUP=1 it replaces the golden file instead.
+func EffectImage(img image.Image) result { //revive:disable-line:unexported-return
+	checkT()
+	t.Helper()
+	imageCalls++
+	got := checkImage(img, goldenImagePath(t.Name(), imageCalls))
+	return track(result{got, defaultReplacer.Replace(got), false, nil, false})
+}
+
+var unsafeFilenameRE = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+func goldenImagePath(testName string, call int) string {
+	name := unsafeFilenameRE.ReplaceAllString(testName, "_")
+	if call > 1 {
+		name += "_" + strconv.Itoa(call)
+	}
+	return filepath.Join("testdata", name+".png")
+}
+
+// imageComparison is the result of comparing an image against its golden file.
+type imageComparison struct {
+	got, golden *image.RGBA     // golden is nil if the golden file is missing or unreadable
+	changed     int             // the number of the changed pixels if the sizes match
+	bounds      image.Rectangle // the bounding box of the changed pixels
+	mismatch    string          // the description of the mismatch, empty if the images match
+}
+
+// compareImage compares img against the golden file at path.
+func compareImage(img image.Image, path string) imageComparison {
+	c := imageComparison{got: toRGBA(img)}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		var goldenImg image.Image
+		if goldenImg, err = png.Decode(bytes.NewReader(data)); err == nil {
+			c.golden = toRGBA(goldenImg)
+		}
+	}
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		c.mismatch = fmt.Sprintf("efft.MissingGolden file=%s: run with EFFUP=1 envvar to create it", path)
+	case err != nil:
+		c.mismatch = fmt.Sprintf("efft.ReadGolden file=%s: %v", path, err)
+	case c.golden.Rect != c.got.Rect:
+		c.mismatch = fmt.Sprintf("efft.ImageSizeDiff file=%s golden=%v runtime=%v", path, c.golden.Rect.Size(), c.got.Rect.Size())
+	default:
+		if c.changed, c.bounds = diffImages(c.golden, c.got); c.changed > 0 {
+			c.mismatch = fmt.Sprintf("efft.ImageDiff file=%s pixels=%d bounds=%v", path, c.changed, c.bounds)
+		}
+	}
+	return c
+}
+
+// checkImage compares img against the golden file and returns the description of the matching image.
+func checkImage(img image.Image, path string) string {
+	t.Helper()
+	c := compareImage(img, path)
+	if c.mismatch == "" {
+		return describeImage(c.golden)
+	}
+	if !updatemode {
+		msg := c.mismatch
+		if c.changed > 0 {
+			diffPath, err := writeDiffImage(c.golden, c.got, path)
+			if err != nil {
+				t.Errorf("efft.WriteDiffImage: %v", err)
+			}
+			msg += " diff=" + diffPath
+		}
+		t.Error(msg)
+		return describeImage(c.got)
+	}
+	if askmode {
+		if err := openReviewer(); err != nil {
+			t.Fatalf("efft.OpenTerminal: %v", err)
+		}
+		old := "(missing)"
+		if c.golden != nil {
+			old = describeImage(c.golden)
+		}
+		d := fmt.Sprintf("-%s\n+%s\n", old, describeImage(c.got))
+		if c.changed > 0 {
+			d += fmt.Sprintf(" pixels=%d bounds=%v\n", c.changed, c.bounds)
+		}
+		if !reviewer.Review(internal.Location{Fname: path, Line: 1}, "", d) {
+			return describeImage(c.got)
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, c.got); err != nil {
+		t.Fatalf("efft.EncodePNG: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("efft.WriteGolden: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("efft.WriteGolden: %v", err)
+	}
+	t.Logf("efft.ExpectationsUpdatedSuccessfully file=%s", path)
+	return describeImage(c.got)
+}
+
+// toRGBA converts img into premultiplied 8-bit RGBA pixels with the origin at (0, 0).
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Src)
+	return rgba
+}
+
+func describeImage(img *image.RGBA) string {
+	return fmt.Sprintf("%dx%d sha256=%x", img.Rect.Dx(), img.Rect.Dy(), sha256.Sum256(img.Pix))
+}
+
+// pixelChanged reports whether the pixels at offset i differ by more than ImageTolerance in any channel.
+func pixelChanged(a, b *image.RGBA, i int) bool {
+	for c := range 4 {
+		if d := int(a.Pix[i+c]) - int(b.Pix[i+c]); d > ImageTolerance || -d > ImageTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// diffImages returns the number and the bounding box of the changed pixels of two images of the same size.
+func diffImages(want, got *image.RGBA) (changed int, bounds image.Rectangle) {
+	for y := range got.Rect.Dy() {
+		for x := range got.Rect.Dx() {
+			if pixelChanged(want, got, got.PixOffset(x, y)) {
+				changed++
+				bounds = bounds.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return changed, bounds
+}
+
+// writeDiffImage writes an image with the changed pixels in red over the faded golden image into a temp file.
+func writeDiffImage(want, got *image.RGBA, path string) (string, error) {
+	diff := image.NewRGBA(got.Rect)
+	for y := range got.Rect.Dy() {
+		for x := range got.Rect.Dx() {
+			if pixelChanged(want, got, got.PixOffset(x, y)) {
+				diff.Set(x, y, color.RGBA{255, 0, 0, 255})
+				continue
+			}
+			gray := color.GrayModel.Convert(want.At(x, y)).(color.Gray).Y
+			faded := 255 - (255-gray)/4
+			diff.Set(x, y, color.RGBA{faded, faded, faded, 255})
+		}
+	}
+	base := filepath.Base(path)
+	f, err := os.CreateTemp("", "efft-*-"+base[:len(base)-len(filepath.Ext(base))]+".diff.png")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := png.Encode(f, diff); err != nil {
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DataBlock is a test case of a data-driven test file.
+type DataBlock struct {
+	Line   int // the line of the command
+	Cmd    string
+	Input  string
+	Output string
+
+	outStart, outEnd int // the line range of the output section after the first separator
+}
+
+// ParseDataFile parses a data-driven test file into its blocks.
+// Each block is a command line, the input lines, a "----" separator and the output lines until an empty line.
+// If the output starts with a second "----" separator then it ends at the next double "----" separator instead so it can contain empty lines.
+// Empty and # prefixed lines between the blocks are ignored.
+func ParseDataFile(fname, src string) ([]DataBlock, error) {
+	lines := strings.Split(strings.TrimSuffix(src, "\n"), "\n")
+	var blocks []DataBlock
+	for i := 0; i < len(lines); {
+		if lines[i] == "" || strings.HasPrefix(lines[i], "#") {
+			i++
+			continue
+		}
+		b := DataBlock{Line: i + 1, Cmd: lines[i]}
+		start := i + 1
+		i++
+		for i < len(lines) && lines[i] != "----" {
+			i++
+		}
+		if i == len(lines) {
+			return nil, fmt.Errorf("efft.MissingSeparator %s:%d", fname, b.Line)
+		}
+		b.Input = strings.Join(lines[start:i], "\n")
+		i++
+		b.outStart = i
+		if i < len(lines) && lines[i] == "----" {
+			start = i + 1
+			i++
+			for i+1 < len(lines) && (lines[i] != "----" || lines[i+1] != "----") {
+				i++
+			}
+			if i+1 >= len(lines) {
+				return nil, fmt.Errorf("efft.MissingDoubleSeparator %s:%d", fname, b.Line)
+			}
+			b.Output = strings.Join(lines[start:i], "\n")
+			i += 2
+		} else {
+			start = i
+			for i < len(lines) && lines[i] != "" {
+				i++
+			}
+			b.Output = strings.Join(lines[start:i], "\n")
+		}
+		b.outEnd = i
+		blocks = append(blocks, b)
+	}
+	return blocks, nil
+}
+
+// UpdateDataFile rewrites the output sections of the blocks in src to the blocks' Output.
+// The blocks must come from ParseDataFile(src).
+func UpdateDataFile(src string, blocks []DataBlock) string {
+	lines := strings.Split(strings.TrimSuffix(src, "\n"), "\n")
+	var updated []string
+	last := 0
+	for _, b := range blocks {
+		updated = append(updated, lines[last:b.outStart]...)
+		var output []string
+		if b.Output != "" {
+			output = strings.Split(b.Output, "\n")
+		}
+		if len(output) > 0 && output[0] == "----" || strings.Contains(b.Output, "\n\n") || strings.HasPrefix(b.Output, "\n") || strings.HasSuffix(b.Output, "\n") {
+			output = append(append([]string{"----"}, output...), "----", "----")
+		} else if b.outEnd < len(lines) && lines[b.outEnd] != "" {
+			// The next block followed a double separator directly but now an empty line must end the output.
+			output = append(output, "")
+		}
+		updated = append(updated, output...)
+		last = b.outEnd
+	}
+	updated = append(updated, lines[last:]...)
+	return strings.Join(updated, "\n") + "\n"
+}
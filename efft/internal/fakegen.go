@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"maps"
+	pathpkg "path"
+	"slices"
+	"strings"
+)
+
+// GenerateFakes returns the source code of the recording fakes for the named interfaces of pkg.
+// The fake of Store is FakeStore.
+// Its <Method>Func fields implement its methods, the methods without them return zero values.
+// Each method call is logged into the efft.Tracer that its Calls method returns.
+func GenerateFakes(pkg *types.Package, names []string) ([]byte, error) {
+	const efftPath = "github.com/ypsu/efftesting/efft"
+	imports := map[string]string{efftPath: "efft"}   // import path -> name in the generated file
+	usedNames := map[string]string{"efft": efftPath} // name in the generated file -> import path
+	qualifier := func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		if name, ok := imports[p.Path()]; ok {
+			return name
+		}
+		// Alias the packages with the same name as an already imported one, e.g. util and util2.
+		name := p.Name()
+		for i := 2; usedNames[name] != ""; i++ {
+			name = fmt.Sprintf("%s%d", p.Name(), i)
+		}
+		imports[p.Path()], usedNames[name] = name, p.Path()
+		return name
+	}
+	body := &bytes.Buffer{}
+	for _, name := range names {
+		tn, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			return nil, fmt.Errorf("efft.TypeNotFound type=%s", name)
+		}
+		iface, ok := tn.Type().Underlying().(*types.Interface)
+		if !ok {
+			return nil, fmt.Errorf("efft.NotInterface type=%s", name)
+		}
+		if named, ok := tn.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+			return nil, fmt.Errorf("efft.UnsupportedGenericInterface type=%s", name)
+		}
+		for i := range iface.NumMethods() {
+			if iface.Method(i).Name() == "Calls" {
+				return nil, fmt.Errorf("efft.MethodNameConflict type=%s method=Calls", name)
+			}
+		}
+		writeFake(body, name, iface, qualifier)
+	}
+
+	src := &bytes.Buffer{}
+	fmt.Fprintf(src, "// Code generated by efftgen; DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg.Name())
+	for _, path := range slices.Sorted(maps.Keys(imports)) {
+		if name := imports[path]; name != pathpkg.Base(path) {
+			fmt.Fprintf(src, "\t%s %q\n", name, path)
+		} else {
+			fmt.Fprintf(src, "\t%q\n", path)
+		}
+	}
+	src.WriteString(")\n")
+	src.Write(body.Bytes())
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("efft.FormatFake: %v", err)
+	}
+	return formatted, nil
+}
+
+func writeFake(w *bytes.Buffer, name string, iface *types.Interface, qualifier types.Qualifier) {
+	fake := "Fake" + name
+	fmt.Fprintf(w, "\n// %s is a recording fake of %s.\n", fake, name)
+	fmt.Fprintf(w, "// The <Method>Func fields implement the methods, the methods without them return zero values.\n")
+	fmt.Fprintf(w, "type %s struct {\n", fake)
+	for i := range iface.NumMethods() {
+		m := iface.Method(i)
+		params, results := signature(m.Type().(*types.Signature), qualifier)
+		fmt.Fprintf(w, "\t%sFunc func(%s) %s\n", m.Name(), params, results)
+	}
+	fmt.Fprintf(w, "\n\tcalls efft.Tracer\n}\n")
+	fmt.Fprintf(w, "\n// Calls returns the trace of the method calls.\n")
+	fmt.Fprintf(w, "func (f *%s) Calls() *efft.Tracer {\n\treturn &f.calls\n}\n", fake)
+
+	for i := range iface.NumMethods() {
+		m := iface.Method(i)
+		sig := m.Type().(*types.Signature)
+		params, results := signature(sig, qualifier)
+		fmt.Fprintf(w, "\nfunc (f *%s) %s(%s) %s {\n", fake, m.Name(), params, results)
+		var args, rets []string
+		for j := range sig.Params().Len() {
+			args = append(args, fmt.Sprintf("p%d", j))
+		}
+		for j := range sig.Results().Len() {
+			rets = append(rets, fmt.Sprintf("r%d", j))
+			fmt.Fprintf(w, "\tvar r%d %s\n", j, types.TypeString(sig.Results().At(j).Type(), qualifier))
+		}
+		callArgs := strings.Join(args, ", ")
+		if sig.Variadic() {
+			callArgs += "..."
+		}
+		fmt.Fprintf(w, "\tif f.%sFunc != nil {\n\t\t", m.Name())
+		if len(rets) > 0 {
+			fmt.Fprintf(w, "%s = ", strings.Join(rets, ", "))
+		}
+		fmt.Fprintf(w, "f.%sFunc(%s)\n\t}\n", m.Name(), callArgs)
+		fmt.Fprintf(w, "\tf.calls.LogCall(%q, []any{%s}, []any{%s})\n", m.Name(), strings.Join(args, ", "), strings.Join(rets, ", "))
+		if len(rets) > 0 {
+			fmt.Fprintf(w, "\treturn %s\n", strings.Join(rets, ", "))
+		}
+		fmt.Fprintf(w, "}\n")
+	}
+}
+
+// signature returns the parameter list with p0, p1, ... names and the result list of sig.
+func signature(sig *types.Signature, qualifier types.Qualifier) (params, results string) {
+	var ps, rs []string
+	for i := range sig.Params().Len() {
+		typ := sig.Params().At(i).Type()
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			ps = append(ps, fmt.Sprintf("p%d ...%s", i, types.TypeString(typ.(*types.Slice).Elem(), qualifier)))
+		} else {
+			ps = append(ps, fmt.Sprintf("p%d %s", i, types.TypeString(typ, qualifier)))
+		}
+	}
+	for i := range sig.Results().Len() {
+		rs = append(rs, types.TypeString(sig.Results().At(i).Type(), qualifier))
+	}
+	results = strings.Join(rs, ", ")
+	if len(rs) > 1 {
+		results = "(" + results + ")"
+	}
+	return strings.Join(ps, ", "), results
+}
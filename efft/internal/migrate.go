@@ -0,0 +1,464 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+const efftPath = "github.com/ypsu/efftesting/efft"
+
+const requirePath = "github.com/stretchr/testify/require"
+
+var testifyPaths = []string{"github.com/stretchr/testify/assert", requirePath}
+
+// migrator rewrites the checks of a single test function.
+type migrator struct {
+	tname   string          // the name of the *testing.T parameter
+	body    *ast.BlockStmt  // the body of the test function
+	testify map[string]bool // the local names of the testify packages, true for require whose checks are fatal
+	count   int             // the number of rewritten checks
+	dropped map[string]bool // the identifiers referenced from the removed code
+	noted   bool            // whether a rewritten check has set efft.Note
+	usesFmt bool            // whether a note uses fmt.Sprintf
+}
+
+// Migrate rewrites testify assertions and `if got != want { t.Errorf(...) }` style checks into efft calls in a test file.
+// Only the checks directly in the test functions are rewritten, the ones in loops and closures are left alone.
+// The require and t.Fatal checks become fatal efft checks, the testify messages become efft.Note assignments.
+// The expectations are left empty, run the tests with EFFUP=1 to fill them in.
+// Returns the new source and the number of rewritten checks.
+func Migrate(fname string, src []byte) ([]byte, int, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, fname, src, parser.ParseComments)
+	if err != nil {
+		return nil, 0, fmt.Errorf("efft.ParseSource: %v", err)
+	}
+	testify := map[string]bool{}
+	var usedImports []*ast.ImportSpec
+	for _, imp := range f.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		if astutil.UsesImport(f, p) {
+			usedImports = append(usedImports, imp)
+		}
+		for _, testifyPath := range testifyPaths {
+			if p != testifyPath {
+				continue
+			}
+			if imp.Name != nil {
+				testify[imp.Name.Name] = p == requirePath
+			} else {
+				testify[path.Base(p)] = p == requirePath
+			}
+		}
+	}
+
+	total, usesFmt := 0, false
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil || !strings.HasPrefix(fn.Name.Name, "Test") || fn.Recv != nil || hasParallel(fn) {
+			continue
+		}
+		m := &migrator{tname: testParamName(fn), body: fn.Body, testify: testify, dropped: map[string]bool{}}
+		if m.tname == "" {
+			continue
+		}
+		fn.Body.List = m.block(fn.Body.List)
+		if m.count == 0 {
+			continue
+		}
+		usesFmt = usesFmt || m.usesFmt
+		m.removeUnused(fn.Body)
+		if !hasInit(fn) {
+			init := &ast.ExprStmt{X: &ast.CallExpr{
+				Fun:    &ast.SelectorExpr{X: &ast.Ident{Name: "efft", NamePos: fn.Body.Lbrace + 1}, Sel: ast.NewIdent("Init")},
+				Args:   []ast.Expr{ast.NewIdent(m.tname)},
+				Rparen: fn.Body.Lbrace + 1,
+			}}
+			fn.Body.List = append([]ast.Stmt{init}, fn.Body.List...)
+		}
+		total += m.count
+	}
+	if total == 0 {
+		return src, 0, nil
+	}
+
+	astutil.AddImport(fset, f, efftPath)
+	if usesFmt {
+		astutil.AddImport(fset, f, "fmt")
+	}
+	for _, imp := range usedImports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		if astutil.UsesImport(f, p) {
+			continue
+		}
+		if imp.Name != nil {
+			astutil.DeleteNamedImport(fset, f, imp.Name.Name, p)
+		} else {
+			astutil.DeleteImport(fset, f, p)
+		}
+	}
+	bs := &bytes.Buffer{}
+	if err := format.Node(bs, fset, f); err != nil {
+		return nil, 0, fmt.Errorf("efft.Format: %v", err)
+	}
+	return bs.Bytes(), total, nil
+}
+
+// testParamName returns the name of the fn's *testing.T parameter or "" if it doesn't have one.
+func testParamName(fn *ast.FuncDecl) string {
+	params := fn.Type.Params.List
+	if len(params) != 1 || len(params[0].Names) != 1 {
+		return ""
+	}
+	star, ok := params[0].Type.(*ast.StarExpr)
+	if !ok {
+		return ""
+	}
+	if sel, ok := star.X.(*ast.SelectorExpr); !ok || sel.Sel.Name != "T" {
+		return ""
+	}
+	return params[0].Names[0].Name
+}
+
+// isCall reports whether n is a pkg.name(...) call.
+func isCall(n ast.Node, pkg, name string) bool {
+	call, ok := n.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && x.Name == pkg && (name == "" || sel.Sel.Name == name)
+}
+
+func hasInit(fn *ast.FuncDecl) bool {
+	found := false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		found = found || isCall(n, "efft", "Init")
+		return !found
+	})
+	return found
+}
+
+func hasParallel(fn *ast.FuncDecl) bool {
+	tname, found := testParamName(fn), false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		found = found || isCall(n, tname, "Parallel")
+		return !found
+	})
+	return found
+}
+
+// efftCall creates an efft.name(arg) statement in place of the old statement.
+func efftCall(old ast.Node, name string, arg ast.Expr) *ast.ExprStmt {
+	return &ast.ExprStmt{X: &ast.CallExpr{
+		Fun:    &ast.SelectorExpr{X: &ast.Ident{Name: "efft", NamePos: old.Pos()}, Sel: ast.NewIdent(name)},
+		Args:   []ast.Expr{arg},
+		Rparen: old.End() - 1,
+	}}
+}
+
+// setNote creates an `efft.Note = note` statement in place of the old statement.
+func setNote(old ast.Node, note ast.Expr) *ast.AssignStmt {
+	return &ast.AssignStmt{
+		Lhs:    []ast.Expr{&ast.SelectorExpr{X: &ast.Ident{Name: "efft", NamePos: old.Pos()}, Sel: ast.NewIdent("Note")}},
+		TokPos: old.Pos(),
+		Tok:    token.ASSIGN,
+		Rhs:    []ast.Expr{note},
+	}
+}
+
+// block rewrites the checks in a statement list and the nested blocks except in loops.
+// Returns the new statement list.
+func (m *migrator) block(stmts []ast.Stmt) []ast.Stmt {
+	var out []ast.Stmt
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.ExprStmt:
+			if repl := m.convertCall(s); repl != nil {
+				out = append(out, repl...)
+				continue
+			}
+		case *ast.IfStmt:
+			if repl := m.convertIf(s); repl != nil {
+				out = append(out, repl...)
+				continue
+			}
+			m.ifStmt(s)
+		case *ast.BlockStmt:
+			s.List = m.block(s.List)
+		case *ast.SwitchStmt:
+			s.Body.List = m.block(s.Body.List)
+		case *ast.TypeSwitchStmt:
+			s.Body.List = m.block(s.Body.List)
+		case *ast.CaseClause:
+			s.Body = m.block(s.Body)
+		}
+		out = append(out, stmt)
+	}
+	return out
+}
+
+// ifStmt rewrites the checks in the branches of an if statement that is not a check itself.
+func (m *migrator) ifStmt(s *ast.IfStmt) {
+	s.Body.List = m.block(s.Body.List)
+	switch e := s.Else.(type) {
+	case *ast.BlockStmt:
+		e.List = m.block(e.List)
+	case *ast.IfStmt:
+		if repl := m.convertIf(e); repl != nil {
+			s.Else = &ast.BlockStmt{Lbrace: e.Pos(), List: repl, Rbrace: e.End() - 1}
+		} else {
+			m.ifStmt(e)
+		}
+	}
+}
+
+// drop marks the identifiers of the removed code so that removeUnused can clean up the variables that become unused.
+func (m *migrator) drop(n ast.Node) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			m.dropped[id.Name] = true
+		}
+		return true
+	})
+}
+
+// note returns the statement that sets efft.Note to the message of a testify call.
+// The message is the format and its args for the f variants, the optional msgAndArgs otherwise.
+// Resets the note if the check has no message but an earlier check has set it.
+func (m *migrator) note(old ast.Node, msgAndArgs []ast.Expr) []ast.Stmt {
+	if len(msgAndArgs) == 0 {
+		if !m.noted {
+			return nil
+		}
+		m.noted = false
+		return []ast.Stmt{setNote(old, &ast.BasicLit{Kind: token.STRING, Value: `""`})}
+	}
+	m.noted = true
+	if lit, ok := msgAndArgs[0].(*ast.BasicLit); ok && lit.Kind == token.STRING && len(msgAndArgs) == 1 {
+		return []ast.Stmt{setNote(old, lit)}
+	}
+	m.usesFmt = true
+	fn := "Sprintf"
+	if len(msgAndArgs) == 1 {
+		fn = "Sprint"
+	}
+	return []ast.Stmt{setNote(old, &ast.CallExpr{
+		Fun:  &ast.SelectorExpr{X: &ast.Ident{Name: "fmt", NamePos: old.Pos()}, Sel: ast.NewIdent(fn)},
+		Args: msgAndArgs,
+	})}
+}
+
+// convertCall rewrites the assert.Equal, assert.NoError and assert.True style testify calls.
+// The require variants become fatal checks, the assert variants don't.
+func (m *migrator) convertCall(s *ast.ExprStmt) []ast.Stmt {
+	call, ok := s.X.(*ast.CallExpr)
+	if !ok || len(call.Args) < 2 {
+		return nil
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	fatal, ok := m.testify[pkg.Name]
+	if !ok {
+		return nil
+	}
+	if t, ok := call.Args[0].(*ast.Ident); !ok || t.Name != m.tname {
+		return nil
+	}
+	switch sel.Sel.Name {
+	case "Equal", "Equalf", "EqualValues", "Exactly":
+		if len(call.Args) < 3 {
+			return nil
+		}
+		m.count++
+		m.drop(call.Args[0])
+		m.drop(call.Args[1])
+		name := "Effect"
+		if fatal {
+			name = "FatalEffect"
+		}
+		return append(m.note(s, call.Args[3:]), efftCall(s, name, call.Args[2]))
+	case "NoError", "NoErrorf", "True", "Truef":
+		m.count++
+		m.drop(call.Args[0])
+		name := "Effect"
+		if fatal {
+			name = "Must"
+		}
+		return append(m.note(s, call.Args[2:]), efftCall(s, name, call.Args[1]))
+	}
+	return nil
+}
+
+// isWant reports whether the expression looks like the expected value of a comparison.
+func isWant(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return strings.HasPrefix(e.Name, "want") || strings.HasPrefix(e.Name, "exp")
+	case *ast.SelectorExpr:
+		return isWant(e.Sel)
+	case *ast.BasicLit, *ast.CompositeLit:
+		return true
+	}
+	return false
+}
+
+// convertIf rewrites `if got != want { t.Errorf(...) }` into efft.Effect(got) and `if err != nil { t.Fatal(err) }` into efft.Must(err).
+// The checks with only t.Fatal calls become fatal checks.
+// The init statement of the if is moved before the check, into a block if it would clash with the other variables.
+func (m *migrator) convertIf(s *ast.IfStmt) []ast.Stmt {
+	if s.Else != nil || len(s.Body.List) == 0 {
+		return nil
+	}
+	fatal := true
+	for _, stmt := range s.Body.List {
+		exprstmt, ok := stmt.(*ast.ExprStmt)
+		if !ok || !isCall(exprstmt.X, m.tname, "") {
+			return nil
+		}
+		switch exprstmt.X.(*ast.CallExpr).Fun.(*ast.SelectorExpr).Sel.Name {
+		case "Error", "Errorf":
+			fatal = false
+		case "Fatal", "Fatalf":
+		default:
+			return nil
+		}
+	}
+	cond, ok := s.Cond.(*ast.BinaryExpr)
+	if !ok || cond.Op != token.NEQ {
+		return nil
+	}
+	m.count++
+	m.drop(s.Body)
+	id, _ := cond.Y.(*ast.Ident)
+	isNil := id != nil && id.Name == "nil"
+	var check ast.Stmt
+	switch {
+	case isNil && fatal:
+		check = efftCall(s, "Must", cond.X)
+	case isNil:
+		check = efftCall(s, "Effect", cond.X)
+	default:
+		got, want := cond.X, cond.Y
+		if isWant(got) {
+			got, want = want, got
+		}
+		m.drop(want)
+		name := "Effect"
+		if fatal {
+			name = "FatalEffect"
+		}
+		check = efftCall(s, name, got)
+	}
+	stmts := append(m.note(s, nil), check)
+	if s.Init == nil {
+		return stmts
+	}
+	stmts = append([]ast.Stmt{s.Init}, stmts...)
+	if assign, ok := s.Init.(*ast.AssignStmt); ok && assign.Tok == token.DEFINE {
+		for _, lhs := range assign.Lhs {
+			if id, ok := lhs.(*ast.Ident); ok && id.Name != "_" && uses(m.body, id.Name) > uses(s, id.Name) {
+				return []ast.Stmt{&ast.BlockStmt{Lbrace: s.Pos(), List: stmts, Rbrace: s.End() - 1}}
+			}
+		}
+	}
+	return stmts
+}
+
+// uses counts the references of the name in n, including its declarations.
+func uses(n ast.Node, name string) int {
+	cnt := 0
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			cnt += uses(n.X, name)
+			return false
+		case *ast.Ident:
+			if n.Name == name {
+				cnt++
+			}
+		}
+		return true
+	})
+	return cnt
+}
+
+func hasCall(n ast.Node) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if _, ok := n.(*ast.CallExpr); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// removeUnused removes or blanks the declarations of the variables that the migration left unused.
+func (m *migrator) removeUnused(body *ast.BlockStmt) {
+	for len(m.dropped) > 0 {
+		var name string
+		for name = range m.dropped {
+			break
+		}
+		delete(m.dropped, name)
+		if uses(body, name) != 1 {
+			continue
+		}
+		astutil.Apply(body, func(c *astutil.Cursor) bool {
+			if spec, ok := c.Node().(*ast.ValueSpec); ok {
+				for _, id := range spec.Names {
+					if id.Name == name {
+						id.Name = "_"
+					}
+				}
+				return false
+			}
+			assign, ok := c.Node().(*ast.AssignStmt)
+			if !ok || assign.Tok != token.DEFINE {
+				return true
+			}
+			for i, lhs := range assign.Lhs {
+				if id, ok := lhs.(*ast.Ident); !ok || id.Name != name {
+					continue
+				}
+				if len(assign.Lhs) == 1 && !hasCall(assign.Rhs[0]) && c.Index() >= 0 {
+					m.drop(assign.Rhs[0])
+					c.Delete()
+					return false
+				}
+				assign.Lhs[i] = ast.NewIdent("_")
+				allBlank := true
+				for _, lhs := range assign.Lhs {
+					if id, ok := lhs.(*ast.Ident); !ok || id.Name != "_" {
+						allBlank = false
+					}
+				}
+				if allBlank {
+					assign.Tok = token.ASSIGN
+				}
+				return false
+			}
+			return true
+		}, nil)
+	}
+}
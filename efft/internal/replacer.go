@@ -13,6 +13,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -33,6 +34,7 @@ type Replacer struct {
 	sync.Mutex
 	Replacements map[Location]string
 	Incomplete   map[Location]bool
+	Hits         map[Location]bool // if non-nil then Replace records all its callers here
 }
 
 // Replace marks the current caller's location to be replaced with newstr.
@@ -41,6 +43,9 @@ func (r *Replacer) Replace(newstr string) Location {
 	_, loc.Fname, loc.Line, _ = runtime.Caller(2)
 	r.Lock()
 	defer r.Unlock()
+	if r.Hits != nil {
+		r.Hits[loc] = true
+	}
 	if _, found := r.Replacements[loc]; found {
 		return Location{}
 	}
@@ -68,25 +73,53 @@ func makelit(s string, indent int) *ast.BasicLit {
 	return &ast.BasicLit{Kind: token.STRING, Value: fmt.Sprintf("`\n%s`", strings.Join(ss, "\n"))}
 }
 
-// Apply applies are stored replacements to a given file.
-func (r *Replacer) Apply(fname string) error {
-	r.Lock()
-	defer r.Unlock()
-	if len(r.Replacements) == 0 {
-		return nil
+// EffectFuncs are the efft functions that return an effect to check with Equals.
+var EffectFuncs = map[string]bool{
+	"Effect":       true,
+	"EffectCmd":    true,
+	"EffectDir":    true,
+	"EffectFS":     true,
+	"EffectGo":     true,
+	"EffectImage":  true,
+	"EffectOutput": true,
+	"EffectPanic":  true,
+	"FatalEffect":  true,
+}
+
+// efftName returns the name under which f imports the efft package or "" if f doesn't import it.
+func efftName(f *ast.File) string {
+	for _, imp := range f.Imports {
+		if p, _ := strconv.Unquote(imp.Path.Value); p != efftPath {
+			continue
+		}
+		if imp.Name != nil {
+			return imp.Name.Name
+		}
+		return "efft"
 	}
+	return ""
+}
 
-	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, fname, nil, parser.ParseComments)
-	if err != nil {
-		return fmt.Errorf("efft.ParseSource: %v", err)
+// efftFunc returns the name of the efft function that call calls or "" if it's not an efft call.
+func efftFunc(call *ast.CallExpr, pkgname string) string {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	if x, ok := sel.X.(*ast.Ident); !ok || x.Name != pkgname {
+		return ""
 	}
+	return sel.Sel.Name
+}
 
-	var inspectErr error
+// inspectEffects calls fn for each statement in f that calls one of the EffectFuncs, optionally followed by an Equals.
+// callexpr is the Effect call itself without the Equals part, rparen is the closing parenthesis of the whole statement.
+func inspectEffects(fset *token.FileSet, f *ast.File, fn func(exprstmt *ast.ExprStmt, callexpr *ast.CallExpr, pos token.Position, rparen token.Pos)) {
+	pkgname := efftName(f)
+	if pkgname == "" {
+		return
+	}
 	ast.Inspect(f, func(n ast.Node) bool {
-		if inspectErr != nil {
-			return false
-		}
 		if n == nil {
 			return true
 		}
@@ -98,27 +131,53 @@ func (r *Replacer) Apply(fname string) error {
 		if !ok {
 			return false // no need to dig deeper than expressions
 		}
-		selexpr, ok := callexpr.Fun.(*ast.SelectorExpr)
-		if !ok {
-			return false // not a function call, so this cannot be efft.Effect() or ...Equals()
-		}
-		funcname, pos, rparen := selexpr.Sel.Name, fset.Position(callexpr.Pos()), callexpr.Rparen
-		if funcname == "Equals" {
+		rparen := callexpr.Rparen
+		if selexpr, ok := callexpr.Fun.(*ast.SelectorExpr); ok && selexpr.Sel.Name == "Equals" {
 			// This might be an Effect's Equals so go to the caller then.
-			callexpr, ok = selexpr.X.(*ast.CallExpr)
-			if !ok {
-				return false
-			}
-			selexpr, ok = callexpr.Fun.(*ast.SelectorExpr)
-			if !ok {
+			if callexpr, ok = selexpr.X.(*ast.CallExpr); !ok {
 				return false
 			}
-			funcname, pos = selexpr.Sel.Name, fset.Position(callexpr.Pos())
 		}
+		if EffectFuncs[efftFunc(callexpr, pkgname)] {
+			fn(exprstmt, callexpr, fset.Position(callexpr.Pos()), rparen)
+		}
+		return false
+	})
+}
+
+// Effects returns the locations of the EffectFuncs calls in a file, e.g. efft.Effect and efft.EffectCmd.
+func Effects(fname string) ([]Location, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, fname, nil, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("efft.ParseSource: %v", err)
+	}
+	var locs []Location
+	inspectEffects(fset, f, func(_ *ast.ExprStmt, _ *ast.CallExpr, pos token.Position, _ token.Pos) {
+		locs = append(locs, Location{pos.Filename, pos.Line})
+	})
+	return locs, nil
+}
+
+// Apply applies are stored replacements to a given file.
+func (r *Replacer) Apply(fname string) error {
+	r.Lock()
+	defer r.Unlock()
+	if len(r.Replacements) == 0 {
+		return nil
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, fname, nil, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("efft.ParseSource: %v", err)
+	}
+
+	inspectEffects(fset, f, func(exprstmt *ast.ExprStmt, callexpr *ast.CallExpr, pos token.Position, rparen token.Pos) {
 		loc := Location{pos.Filename, pos.Line}
 		repl, found := r.Replacements[loc]
-		if !found || funcname != "Effect" && funcname != "FatalEffect" {
-			return false
+		if !found {
+			return
 		}
 		delete(r.Replacements, loc)
 
@@ -127,11 +186,7 @@ func (r *Replacer) Apply(fname string) error {
 			Args:   []ast.Expr{makelit(repl, pos.Column)},
 			Rparen: rparen,
 		}
-		return false
 	})
-	if inspectErr != nil {
-		return fmt.Errorf("efft.Rewrite: %v", inspectErr)
-	}
 	if len(r.Replacements) > 0 {
 		lines := make([]int, 0, len(r.Replacements))
 		for loc := range r.Replacements {
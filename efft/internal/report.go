@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Record describes the outcome of a single effect.
+// The EFFREPORT file contains one JSON encoded Record per line.
+type Record struct {
+	Fname    string `json:"file"`
+	Line     int    `json:"line"`
+	Test     string `json:"test"`
+	Status   string `json:"status"` // ok, incomplete or wrong
+	Expected string `json:"expected,omitempty"`
+	Got      string `json:"got"`
+	Note     string `json:"note,omitempty"`
+	Fatal    bool   `json:"fatal"`
+}
+
+// ReadReport reads the records from a report and returns the replacements that fix the failing expectations.
+// Later records override the earlier ones so reports from multiple runs can be concatenated.
+func ReadReport(r io.Reader) (map[Location]string, error) {
+	replacements := map[Location]string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<30)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("efft.ParseRecord line=%d: %v", line, err)
+		}
+		if record.Fname == "" {
+			continue
+		}
+		loc := Location{record.Fname, record.Line}
+		if record.Status == "ok" {
+			delete(replacements, loc)
+		} else {
+			replacements[loc] = record.Got
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("efft.ReadReport: %v", err)
+	}
+	return replacements, nil
+}
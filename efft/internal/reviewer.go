@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Reviewer asks the user one by one whether to accept the replacements.
+type Reviewer struct {
+	In  *bufio.Reader
+	Out io.Writer
+
+	acceptAll, quit bool
+}
+
+// Review shows the diff of the replacement at loc and returns whether the user accepted it.
+// After an accept-all answer it accepts everything without asking, after a quit it rejects everything.
+func (r *Reviewer) Review(loc Location, note, diff string) bool {
+	if r.quit {
+		return false
+	}
+	if r.acceptAll {
+		return true
+	}
+	if note != "" {
+		note = " note=`" + note + "`"
+	}
+	fmt.Fprintf(r.Out, "efft.Review %s%s -expectation +runtime:\n%s", loc, note, diff)
+	for {
+		fmt.Fprint(r.Out, "accept? [a]ccept, [s]kip, [A]ccept all, [q]uit: ")
+		answer, err := r.In.ReadString('\n')
+		if err != nil && answer == "" {
+			fmt.Fprintln(r.Out)
+			r.quit = true
+			return false
+		}
+		switch strings.TrimSpace(answer) {
+		case "a":
+			return true
+		case "s":
+			return false
+		case "A":
+			r.acceptAll = true
+			return true
+		case "q":
+			r.quit = true
+			return false
+		}
+	}
+}
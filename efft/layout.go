@@ -0,0 +1,118 @@
+package efft
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// CompactWidth enables the compact JSON layout in Stringify if it's positive.
+// Then the arrays and objects that fit into CompactWidth columns are printed on a single line, e.g. `[1, 2, 3]`,
+// and only the ones that don't fit are broken into multiple lines.
+var CompactWidth int
+
+// jsonNode is a parsed JSON value that keeps the order of the object keys.
+type jsonNode struct {
+	scalar  string      // the encoded value if it's not an array or object
+	delim   json.Delim  // '[' or '{' for arrays and objects
+	keys    []string    // the encoded keys of an object
+	members []*jsonNode // the elements of an array or the values of an object
+}
+
+// parseJSON parses the next value from d.
+func parseJSON(d *json.Decoder) (*jsonNode, error) {
+	tok, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		js, err := json.Marshal(tok)
+		return &jsonNode{scalar: string(js)}, err
+	}
+	n := &jsonNode{delim: delim}
+	for d.More() {
+		if delim == '{' {
+			key, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			js, err := json.Marshal(key)
+			if err != nil {
+				return nil, err
+			}
+			n.keys = append(n.keys, string(js))
+		}
+		member, err := parseJSON(d)
+		if err != nil {
+			return nil, err
+		}
+		n.members = append(n.members, member)
+	}
+	_, err = d.Token() // the closing delimiter
+	return n, err
+}
+
+// compact renders n on a single line.
+func (n *jsonNode) compact() string {
+	if n.delim == 0 {
+		return n.scalar
+	}
+	parts := make([]string, len(n.members))
+	for i, member := range n.members {
+		parts[i] = member.compact()
+		if n.delim == '{' {
+			parts[i] = n.keys[i] + ": " + parts[i]
+		}
+	}
+	if n.delim == '{' {
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// layout writes n onto sb.
+// The current line already has prefixWidth characters and indent is the indentation of the current line.
+func (n *jsonNode) layout(sb *strings.Builder, indent string, prefixWidth int) {
+	if s := n.compact(); n.delim == 0 || len(n.members) == 0 || prefixWidth+utf8.RuneCountInString(s) <= CompactWidth {
+		sb.WriteString(s)
+		return
+	}
+	closing := "]"
+	if n.delim == '{' {
+		closing = "}"
+	}
+	sb.WriteString(n.delim.String() + "\n")
+	inner := indent + "  "
+	for i, member := range n.members {
+		sb.WriteString(inner)
+		width := len(inner)
+		if n.delim == '{' {
+			sb.WriteString(n.keys[i] + ": ")
+			width += utf8.RuneCountInString(n.keys[i]) + 2
+		}
+		if i < len(n.members)-1 {
+			width++ // the comma
+		}
+		member.layout(sb, inner, width)
+		if i < len(n.members)-1 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(indent + closing)
+}
+
+// compactJSON lays out the JSON encoded js according to CompactWidth.
+func compactJSON(js []byte) (string, error) {
+	d := json.NewDecoder(bytes.NewReader(js))
+	d.UseNumber()
+	n, err := parseJSON(d)
+	if err != nil {
+		return "", err
+	}
+	sb := &strings.Builder{}
+	n.layout(sb, "", 0)
+	return sb.String(), nil
+}
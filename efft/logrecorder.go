@@ -0,0 +1,106 @@
+package efft
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// LogRecorder is a slog.Handler that records the logs in a deterministic text format.
+// Install it e.g. with `efft.Override(&logger, slog.New(rec))` and then check the logs with `efft.Effect(rec)`.
+//
+// Each record is a line with the level, the message and then the attributes as key=value pairs.
+// There are no timestamps.
+// The handler's attributes come first in the order they were added, then the record's attributes in order.
+// The members of the groups are sorted by their keys and are prefixed with the group names, e.g. `request.id=1`.
+// The zero value is ready to use.
+type LogRecorder struct {
+	// Level is the minimum level to record.
+	// Records all levels if nil.
+	Level slog.Leveler
+
+	root   *LogRecorder // the recorder that holds the records, nil for the root itself
+	mu     sync.Mutex
+	lines  []string
+	attrs  []string // the formatted attributes from WithAttrs
+	prefix string   // the group prefix from WithGroup, e.g. "a.b."
+}
+
+func (r *LogRecorder) rootRecorder() *LogRecorder {
+	if r.root != nil {
+		return r.root
+	}
+	return r
+}
+
+// Enabled implements slog.Handler.
+func (r *LogRecorder) Enabled(_ context.Context, level slog.Level) bool {
+	root := r.rootRecorder()
+	return root.Level == nil || level >= root.Level.Level()
+}
+
+// Handle implements slog.Handler.
+func (r *LogRecorder) Handle(_ context.Context, record slog.Record) error {
+	fields := append([]string{record.Level.String(), record.Message}, r.attrs...)
+	record.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, r.prefix, a)
+		return true
+	})
+	root := r.rootRecorder()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	root.lines = append(root.lines, strings.Join(fields, " "))
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (r *LogRecorder) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := &LogRecorder{root: r.rootRecorder(), attrs: slices.Clip(r.attrs), prefix: r.prefix}
+	for _, a := range attrs {
+		clone.attrs = appendAttr(clone.attrs, r.prefix, a)
+	}
+	return clone
+}
+
+// WithGroup implements slog.Handler.
+func (r *LogRecorder) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return r
+	}
+	return &LogRecorder{root: r.rootRecorder(), attrs: r.attrs, prefix: r.prefix + name + "."}
+}
+
+// String returns the recorded logs, one record per line.
+func (r *LogRecorder) String() string {
+	root := r.rootRecorder()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	return strings.Join(root.lines, "\n")
+}
+
+func appendAttr(fields []string, prefix string, a slog.Attr) []string {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		group := slices.Clone(v.Group())
+		slices.SortStableFunc(group, func(a, b slog.Attr) int { return strings.Compare(a.Key, b.Key) })
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, member := range group {
+			fields = appendAttr(fields, prefix, member)
+		}
+		return fields
+	}
+	if a.Key == "" {
+		return fields
+	}
+	s := v.String()
+	if s == "" || strings.ContainsFunc(s, func(r rune) bool { return unicode.IsSpace(r) || r == '=' || r == '"' || !unicode.IsPrint(r) }) {
+		s = strconv.Quote(s)
+	}
+	return append(fields, prefix+a.Key+"="+s)
+}
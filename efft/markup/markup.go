@@ -0,0 +1,241 @@
+// Package markup canonicalizes JSON, XML and HTML documents for the efft expectations.
+// Use it as `efft.Effect(markup.JSON(doc))`, a document that doesn't parse makes the parse error the expectation.
+package markup
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// JSON returns the canonical form of a JSON document.
+// The canonical form is indented with 2 spaces, the object keys are sorted and the numbers are kept as written.
+func JSON[T ~string | ~[]byte](doc T) (string, error) {
+	got, err := canonicalJSON([]byte(doc))
+	if err != nil {
+		return "", fmt.Errorf("efft.ParseJSON %v", err)
+	}
+	return got, nil
+}
+
+// XML returns the canonical form of an XML document.
+// The canonical form is indented with 2 spaces, the attributes are sorted and the whitespace-only text is dropped.
+func XML[T ~string | ~[]byte](doc T) (string, error) {
+	got, err := canonicalXML([]byte(doc))
+	if err != nil {
+		return "", fmt.Errorf("efft.ParseXML %v", err)
+	}
+	return got, nil
+}
+
+// HTML returns the canonical form of an HTML document or fragment.
+// The canonical form has each element on its own line indented with 2 spaces, the attributes are sorted and the whitespace is collapsed.
+// The content of the pre, textarea, script and style elements is kept as is.
+// A full document is parsed if doc starts with a doctype or an html tag after the leading comments, otherwise it's parsed as a fragment of the body.
+func HTML[T ~string | ~[]byte](doc T) (string, error) {
+	got, err := canonicalHTML([]byte(doc))
+	if err != nil {
+		return "", fmt.Errorf("efft.ParseHTML %v", err)
+	}
+	return got, nil
+}
+
+func canonicalJSON(doc []byte) (string, error) {
+	d := json.NewDecoder(bytes.NewReader(doc))
+	d.UseNumber()
+	var v any
+	err := d.Decode(&v)
+	if err == nil {
+		if _, tokErr := d.Token(); tokErr != io.EOF {
+			err = errors.New("unexpected data after the document")
+		}
+	}
+	if err != nil {
+		offset := d.InputOffset()
+		if syntaxErr := (*json.SyntaxError)(nil); errors.As(err, &syntaxErr) {
+			offset = syntaxErr.Offset
+		}
+		line := 1 + bytes.Count(doc[:min(offset, int64(len(doc)))], []byte("\n"))
+		return "", fmt.Errorf("line=%d: %v", line, err)
+	}
+	sb := &strings.Builder{}
+	e := json.NewEncoder(sb)
+	e.SetEscapeHTML(false)
+	e.SetIndent("", "  ")
+	if err := e.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+func canonicalXML(doc []byte) (string, error) {
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	sb := &strings.Builder{}
+	e := xml.NewEncoder(sb)
+	e.Indent("", "  ")
+	// RawToken keeps the namespace prefixes as written, the encoder still checks that the tags match.
+	prefixed := func(name xml.Name) xml.Name {
+		if name.Space == "" {
+			return name
+		}
+		return xml.Name{Local: name.Space + ":" + name.Local}
+	}
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			tok.Name = prefixed(tok.Name)
+			for i := range tok.Attr {
+				tok.Attr[i].Name = prefixed(tok.Attr[i].Name)
+			}
+			slices.SortFunc(tok.Attr, func(a, b xml.Attr) int { return strings.Compare(a.Name.Local, b.Name.Local) })
+			err = e.EncodeToken(tok)
+		case xml.EndElement:
+			tok.Name = prefixed(tok.Name)
+			err = e.EncodeToken(tok)
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) > 0 {
+				err = e.EncodeToken(xml.CharData(bytes.TrimSpace(tok)))
+			}
+		case xml.ProcInst:
+			if tok.Target == "xml" {
+				continue
+			}
+			err = e.EncodeToken(tok)
+		default:
+			err = e.EncodeToken(tok)
+		}
+		if err != nil {
+			return "", fmt.Errorf("line=%d: %v", lineOf(d), err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		return "", fmt.Errorf("line=%d: %v", lineOf(d), err)
+	}
+	return sb.String(), nil
+}
+
+func lineOf(d *xml.Decoder) int {
+	line, _ := d.InputPos()
+	return line
+}
+
+// htmlRawElements are the elements whose content is kept as is.
+var htmlRawElements = []string{"pre", "textarea", "script", "style"}
+
+func canonicalHTML(doc []byte) (string, error) {
+	var nodes []*html.Node
+	if isDocument(doc) {
+		root, err := html.Parse(bytes.NewReader(doc))
+		if err != nil {
+			return "", err
+		}
+		for n := range root.ChildNodes() {
+			nodes = append(nodes, n)
+		}
+	} else {
+		var err error
+		body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+		if nodes, err = html.ParseFragment(bytes.NewReader(doc), body); err != nil {
+			return "", err
+		}
+	}
+	var lines []string
+	for _, n := range nodes {
+		lines = appendHTML(lines, n, "")
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// isDocument reports whether doc starts with a doctype or an html tag after the leading whitespace and comments.
+func isDocument(doc []byte) bool {
+	for {
+		doc = bytes.TrimSpace(doc)
+		if !bytes.HasPrefix(doc, []byte("<!--")) {
+			break
+		}
+		_, rest, found := bytes.Cut(doc, []byte("-->"))
+		if !found {
+			return false
+		}
+		doc = rest
+	}
+	start := strings.ToLower(string(doc[:min(len(doc), 9)]))
+	return strings.HasPrefix(start, "<!doctype") || strings.HasPrefix(start, "<html")
+}
+
+func appendHTML(lines []string, n *html.Node, indent string) []string {
+	switch n.Type {
+	case html.TextNode:
+		if text := strings.Join(strings.Fields(n.Data), " "); text != "" {
+			lines = append(lines, indent+html.EscapeString(text))
+		}
+	case html.CommentNode:
+		lines = append(lines, indent+"<!--"+n.Data+"-->")
+	case html.DoctypeNode:
+		lines = append(lines, indent+"<!DOCTYPE "+n.Data+">")
+	case html.ElementNode:
+		tag := "<" + n.Data
+		attrs := slices.Clone(n.Attr)
+		slices.SortFunc(attrs, func(a, b html.Attribute) int {
+			return cmp.Or(strings.Compare(a.Namespace, b.Namespace), strings.Compare(a.Key, b.Key))
+		})
+		for _, a := range attrs {
+			key := a.Key
+			if a.Namespace != "" {
+				key = a.Namespace + ":" + key
+			}
+			tag += " " + key + `="` + html.EscapeString(a.Val) + `"`
+		}
+		tag += ">"
+		end := "</" + n.Data + ">"
+		switch {
+		case isVoidElement(n.Data):
+			lines = append(lines, indent+tag)
+		case slices.Contains(htmlRawElements, n.Data):
+			sb := &strings.Builder{}
+			for c := range n.ChildNodes() {
+				html.Render(sb, c)
+			}
+			lines = append(lines, indent+tag+sb.String()+end)
+		case n.FirstChild == nil:
+			lines = append(lines, indent+tag+end)
+		case n.FirstChild == n.LastChild && n.FirstChild.Type == html.TextNode:
+			lines = append(lines, indent+tag+html.EscapeString(strings.Join(strings.Fields(n.FirstChild.Data), " "))+end)
+		default:
+			lines = append(lines, indent+tag)
+			for c := range n.ChildNodes() {
+				lines = appendHTML(lines, c, indent+"  ")
+			}
+			lines = append(lines, indent+end)
+		}
+	default:
+		for c := range n.ChildNodes() {
+			lines = appendHTML(lines, c, indent)
+		}
+	}
+	return lines
+}
+
+func isVoidElement(tag string) bool {
+	switch tag {
+	case "area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "source", "track", "wbr":
+		return true
+	}
+	return false
+}
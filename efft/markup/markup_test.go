@@ -0,0 +1,72 @@
+package markup_test
+
+import (
+	"testing"
+
+	"github.com/ypsu/efftesting/efft"
+	"github.com/ypsu/efftesting/efft/markup"
+)
+
+func TestMarkup(t *testing.T) {
+	efft.Init(t)
+	efft.Effect(markup.JSON(`{"b": [1, 2.50, {"d": null, "c": "<&>"}], "a": true}`)).Equals(`
+		{
+		  "a": true,
+		  "b": [
+		    1,
+		    2.50,
+		    {
+		      "c": "<&>",
+		      "d": null
+		    }
+		  ]
+		}`)
+	efft.Effect(markup.JSON([]byte("[]"))).Equals("[]")
+	efft.Effect(markup.XML(`<?xml version="1.0"?>
+		<root b="2" a="1"><ns:item id="x">  text &amp; more </ns:item>
+		<!-- comment --><empty/></root>`)).Equals(`
+		<root a="1" b="2">
+		  <ns:item id="x">text &amp; more</ns:item><!-- comment -->
+		  <empty></empty>
+		</root>`)
+	efft.Effect(markup.HTML(`<div class="b a" id=main><p>Hello,
+		<b>world</b>!</p><br><img src="x.png" alt='say "hi"'><pre>  keep
+	  this  </pre></div>`)).Equals(`
+		<div class="b a" id="main">
+		  <p>
+		    Hello,
+		    <b>world</b>
+		    !
+		  </p>
+		  <br>
+		  <img alt="say &#34;hi&#34;" src="x.png">
+		  <pre>  keep
+			  this  </pre>
+		</div>`)
+	efft.Effect(markup.HTML("<!DOCTYPE html><title>T</title><p>body")).Equals(`
+		<!DOCTYPE html>
+		<html>
+		  <head>
+		    <title>T</title>
+		  </head>
+		  <body>
+		    <p>body</p>
+		  </body>
+		</html>`)
+	efft.Effect(markup.HTML("<!-- generated -->\n<!DOCTYPE html><title>T</title>")).Equals(`
+		<!-- generated -->
+		<!DOCTYPE html>
+		<html>
+		  <head>
+		    <title>T</title>
+		  </head>
+		  <body></body>
+		</html>`)
+
+	// The parse errors.
+	efft.Effect(markup.JSON("{\n  \"a\": 1,\n}")).Equals("efft.ParseJSON line=3: invalid character '}' looking for beginning of object key string")
+	efft.Effect(markup.JSON(`{"a": 1} {}`)).Equals("efft.ParseJSON line=1: unexpected data after the document")
+	efft.Effect(markup.JSON("")).Equals("efft.ParseJSON line=1: EOF")
+	efft.Effect(markup.XML("<a>\n<b></a>")).Equals("efft.ParseXML line=2: xml: end tag </a> does not match start tag <b>")
+	efft.Effect(markup.XML("<a>")).Equals("efft.ParseXML line=1: unclosed tag <a>")
+}
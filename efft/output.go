@@ -0,0 +1,141 @@
+package efft
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// EffectOutput calls fn and sets up an expectation for everything it printed.
+// It captures os.Stdout, os.Stderr, the log package's default logger and slog.Default() for the duration of the call.
+// All of them are restored when EffectOutput returns.
+// Each output line is prefixed with its stream's name: "stdout: ", "stderr: ", "log: " or "slog: ".
+// The timestamps are omitted from the log and slog lines.
+//
+// The log and slog lines appear in the order they were written.
+// os.Stdout and os.Stderr are pipes so only the kernel knows the order of their writes.
+// Therefore between two log lines all the stdout lines come first and then all the stderr lines.
+func EffectOutput(fn func()) result { //revive:disable-line:unexported-return
+	checkT()
+	t.Helper()
+	got := scrub(captureOutput(fn))
+	return track(result{got, defaultReplacer.Replace(got), false, nil, false})
+}
+
+// syncMarker is written into the pipes to find out which part of their content was written before a log line.
+const syncMarker = "\x00efft.SyncMarker\x00"
+
+// pipeStream captures an *os.File via a pipe.
+type pipeStream struct {
+	name   string
+	r, w   *os.File
+	synced chan []byte // the content before the latest syncMarker
+}
+
+func (s *pipeStream) run() {
+	var buf []byte
+	chunk := make([]byte, 4096)
+	for {
+		n, err := s.r.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		for {
+			i := bytes.Index(buf, []byte(syncMarker))
+			if i == -1 {
+				break
+			}
+			s.synced <- buf[:i:i]
+			buf = buf[i+len(syncMarker):]
+		}
+		if err != nil {
+			close(s.synced)
+			return
+		}
+	}
+}
+
+// outputCapturer collects the labeled lines of all the streams.
+type outputCapturer struct {
+	mu      sync.Mutex
+	lines   []string
+	streams []*pipeStream
+}
+
+func (c *outputCapturer) add(name string, content []byte) {
+	if len(content) == 0 {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		c.lines = append(c.lines, name+": "+line)
+	}
+}
+
+// sync moves the pipes' content into the lines.
+func (c *outputCapturer) sync() {
+	for _, s := range c.streams {
+		s.w.WriteString(syncMarker)
+		c.add(s.name, <-s.synced)
+	}
+}
+
+// streamWriter is the io.Writer for the log and slog streams.
+type streamWriter struct {
+	c    *outputCapturer
+	name string
+}
+
+func (w streamWriter) Write(p []byte) (int, error) {
+	w.c.mu.Lock()
+	defer w.c.mu.Unlock()
+	w.c.sync()
+	w.c.add(w.name, p)
+	return len(p), nil
+}
+
+func captureOutput(fn func()) string {
+	c := &outputCapturer{}
+	for _, name := range []string{"stdout", "stderr"} {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("efft.CreatePipe: %v", err)
+		}
+		s := &pipeStream{name, r, w, make(chan []byte)}
+		c.streams = append(c.streams, s)
+		go s.run()
+	}
+
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	oldSlog, oldLogOutput, oldLogFlags := slog.Default(), log.Writer(), log.Flags()
+	defer func() {
+		// Restore slog first because slog.SetDefault can change the log package's output too.
+		slog.SetDefault(oldSlog)
+		log.SetOutput(oldLogOutput)
+		log.SetFlags(oldLogFlags)
+		os.Stdout, os.Stderr = oldStdout, oldStderr
+		for _, s := range c.streams {
+			s.w.Close()
+			for range s.synced {
+			}
+			s.r.Close()
+		}
+	}()
+	os.Stdout, os.Stderr = c.streams[0].w, c.streams[1].w
+	slog.SetDefault(slog.New(slog.NewTextHandler(streamWriter{c, "slog"}, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+	log.SetOutput(streamWriter{c, "log"})
+	log.SetFlags(0)
+
+	fn()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sync()
+	return strings.Join(c.lines, "\n")
+}
@@ -0,0 +1,83 @@
+package efft
+
+import (
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// PanicStack makes EffectPanic include the stack trace of the panic.
+// The trace contains only the function and file names of the frames between the panic and fn, without addresses and line numbers.
+// This keeps the expectation stable across unrelated code changes.
+var PanicStack bool
+
+// EffectPanic calls fn and sets up an expectation for its panic.
+// fn must be a function without arguments but it can have any return values, e.g. `func() (int, error)`.
+// The result is `panic: <value>` if fn panicked or `no panic: <return values>` otherwise.
+// Both the panic value and the return values are stringified the same way as in Effect.
+func EffectPanic(fn any) result { //revive:disable-line:unexported-return
+	checkT()
+	t.Helper()
+	got := scrub(stringifyPanic(fn))
+	return track(result{got, defaultReplacer.Replace(got), false, nil, false})
+}
+
+func stringifyPanic(fn any) string {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.Type().NumIn() != 0 {
+		t.Fatalf("efft.BadPanicFunc type=%T: EffectPanic needs a function without arguments", fn)
+	}
+	results, panicked, value, stack := callRecover(v)
+	if !panicked {
+		if len(results) == 0 {
+			return "no panic"
+		}
+		return "no panic: " + Stringify(results...)
+	}
+	s := "panic: " + Stringify(value)
+	if PanicStack {
+		s += "\nstack:\n" + stack
+	}
+	return s
+}
+
+// callRecover calls fn and recovers its panic if there's any.
+func callRecover(fn reflect.Value) (results []any, panicked bool, value any, stack string) {
+	defer func() {
+		if panicked {
+			value = recover()
+			if PanicStack {
+				stack = panicStack()
+			}
+		}
+	}()
+	panicked = true
+	for _, r := range fn.Call(nil) {
+		results = append(results, r.Interface())
+	}
+	return results, false, nil, ""
+}
+
+// panicStack returns the frames between the panic and the reflect call of callRecover.
+// Must be called from the deferred function that recovers the panic.
+func panicStack() string {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(1, pcs)])
+	var lines []string
+	for inPanic := false; ; {
+		frame, more := frames.Next()
+		switch {
+		case strings.HasPrefix(frame.Function, "reflect."):
+			return strings.Join(lines, "\n")
+		case frame.Function == "runtime.gopanic":
+			inPanic = true
+		case inPanic && !strings.HasPrefix(frame.Function, "runtime."):
+			funcname := frame.Function[strings.LastIndexByte(frame.Function, '/')+1:]
+			lines = append(lines, "  "+funcname+" ("+filepath.Base(frame.File)+")")
+		}
+		if !more {
+			return strings.Join(lines, "\n")
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package efft
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// RunResult is the outcome of a program run.
+// It stringifies into the exit code followed by the non-empty output streams:
+//
+//	exit 1
+//	--- stdout
+//	some output
+//	--- stderr
+//	some error
+type RunResult struct {
+	ExitCode       int
+	Stdout, Stderr string
+}
+
+func (r RunResult) String() string {
+	s := "exit " + strconv.Itoa(r.ExitCode)
+	if r.Stdout != "" {
+		s += "\n--- stdout\n" + strings.TrimSuffix(r.Stdout, "\n")
+	}
+	if r.Stderr != "" {
+		s += "\n--- stderr\n" + strings.TrimSuffix(r.Stderr, "\n")
+	}
+	return s
+}
+
+var (
+	// runMainCalls is the number of RunMain calls in the current test.
+	runMainCalls int
+
+	// runMainTarget is the RunMain call to execute in the RunMain subprocess, 0 in the normal test process.
+	runMainTarget, _ = strconv.Atoi(os.Getenv("EFFTESTING_RUNMAIN"))
+)
+
+// RunMain runs mainFunc as a program's main function and returns its exit code and output.
+// args is the whole command line including the program name, RunMain sets os.Args to it.
+// stdin is the program's standard input.
+// Use it as `efft.Effect(efft.RunMain(main, []string{"prog", "-flag"}, ""))`.
+//
+// Go cannot intercept os.Exit calls so mainFunc runs in a subprocess:
+// the test binary reruns the current test and executes the mainFunc of the same RunMain call there.
+// Therefore the test must behave the same way up until the RunMain call.
+// In the subprocess the other RunMain calls return an empty RunResult,
+// and the expectations and the Must checks are no-ops so that they don't abort the test before the RunMain call.
+func RunMain(mainFunc func(), args []string, stdin string) RunResult {
+	checkT()
+	t.Helper()
+	runMainCalls++
+	if runMainTarget != 0 {
+		if runMainCalls == runMainTarget {
+			runMainChild(mainFunc, args)
+		}
+		return RunResult{}
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^"+regexp.QuoteMeta(t.Name())+"$")
+	for _, env := range os.Environ() {
+		if name, _, _ := strings.Cut(env, "="); name != "EFFUP" && name != "EFFREPORT" && name != "EFFSTALE" {
+			cmd.Env = append(cmd.Env, env)
+		}
+	}
+	cmd.Env = append(cmd.Env, "EFFTESTING_RUNMAIN="+strconv.Itoa(runMainCalls))
+	cmd.Stdin = strings.NewReader(stdin)
+	r, err := runResult(cmd)
+	if err != nil {
+		t.Fatalf("efft.RunMain: %v", err)
+	}
+	return r
+}
+
+// runResult runs cmd and collects its exit code and output.
+// The error is non-nil only if cmd couldn't run, a non-zero exit code is not an error.
+func runResult(cmd *exec.Cmd) (RunResult, error) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	r := RunResult{}
+	if err := cmd.Run(); err != nil {
+		exitErr := &exec.ExitError{}
+		if !errors.As(err, &exitErr) {
+			return r, err
+		}
+		r.ExitCode = exitErr.ExitCode()
+	}
+	r.Stdout, r.Stderr = stdout.String(), stderr.String()
+	return r, nil
+}
+
+// runMainChild runs mainFunc and exits the RunMain subprocess.
+func runMainChild(mainFunc func(), args []string) {
+	defer func() {
+		// The testing package turns os.Exit(0) into a panic during tests.
+		if r := recover(); r != nil && fmt.Sprint(r) != "unexpected call to os.Exit(0) during test" {
+			panic(r)
+		}
+		syscall.Exit(0)
+	}()
+	os.Args = args
+	mainFunc()
+}
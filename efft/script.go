@@ -0,0 +1,251 @@
+package efft
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/txtar"
+)
+
+// Script is the state of a RunScript script.
+type Script struct {
+	Work string   // the temporary directory with the archive's files, also available as $WORK
+	Dir  string   // the current directory
+	Env  []string // the environment of the commands in the KEY=VALUE format
+
+	stdout string // the last exec's stdout
+}
+
+// Getenv returns the value of the environment variable key in the script.
+func (s *Script) Getenv(key string) string {
+	for _, env := range slices.Backward(s.Env) {
+		if k, v, _ := strings.Cut(env, "="); k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// Path resolves name relative to the current directory.
+func (s *Script) Path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.Dir, name)
+}
+
+// ScriptCmd is a user-defined RunScript command.
+// It gets the command's arguments without the command name and the input section.
+// Its result is the command's output.
+type ScriptCmd func(s *Script, args []string, input string) string
+
+// RunScript runs the script tests in the txtar archives matching glob, each in its own subtest.
+// The archive's files are extracted into a temporary directory, then the archive's comment runs as a script.
+// The script uses the RunFile format: each block is a command line, an input, a "----" separator and the expected output.
+// The command lines undergo $VAR expansion and are split at spaces, single quotes group words into one argument.
+// EFFUP=1 rewrites the output sections, the files are kept as they are.
+//
+//	exec cat greeting.txt
+//	----
+//	exit 0
+//	--- stdout
+//	hello
+//
+//	-- greeting.txt --
+//	hello
+//
+// The builtin commands:
+//
+//   - exec prog args...: runs prog with the input as its stdin and outputs its result in the RunResult format.
+//     The environment consists of PATH, HOME=$WORK, WORK, LC_ALL=C and TZ=UTC.
+//     The $WORK path is scrubbed from the output.
+//   - stdout: outputs the last exec's stdout.
+//   - cd dir: changes the current directory.
+//   - env: outputs the environment. `env KEY=VALUE...` sets variables, `env KEY...` outputs them.
+//   - cp src dst: copies a file.
+//   - cmp a b: outputs the diff of two files, nothing if they are the same.
+//
+// cmds are extra commands, they take precedence over the builtin ones.
+func RunScript(tt *testing.T, glob string, cmds map[string]ScriptCmd) {
+	tt.Helper()
+	fnames, err := filepath.Glob(glob)
+	if err != nil {
+		tt.Fatalf("efft.BadGlob: %v", err)
+	}
+	if len(fnames) == 0 {
+		tt.Fatalf("efft.NoScripts glob=%q", glob)
+	}
+	for _, fname := range fnames {
+		tt.Run(filepath.Base(fname), func(tt *testing.T) { runScript(tt, fname, cmds) })
+	}
+}
+
+func runScript(tt *testing.T, fname string, cmds map[string]ScriptCmd) {
+	tt.Helper()
+	archive, err := txtar.ParseFile(fname)
+	if err != nil {
+		tt.Fatalf("efft.ReadScript: %v", err)
+	}
+	work := tt.TempDir()
+	for _, f := range archive.Files {
+		if !filepath.IsLocal(f.Name) {
+			tt.Fatalf("efft.BadArchivePath name=%q", f.Name)
+		}
+		path := filepath.Join(work, f.Name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			tt.Fatalf("efft.ExtractArchive: %v", err)
+		}
+		if err := os.WriteFile(path, f.Data, 0644); err != nil {
+			tt.Fatalf("efft.ExtractArchive: %v", err)
+		}
+	}
+	s := &Script{
+		Work: work,
+		Dir:  work,
+		Env:  []string{"PATH=" + os.Getenv("PATH"), "HOME=" + work, "WORK=" + work, "LC_ALL=C", "TZ=UTC"},
+	}
+	comment, ok := checkData(tt, fname, string(archive.Comment), func(line, input string) string {
+		args := splitArgs(os.Expand(line, s.Getenv))
+		if len(args) == 0 {
+			return "efft.EmptyCommand"
+		}
+		if cmd, found := cmds[args[0]]; found {
+			return cmd(s, args[1:], input)
+		}
+		return s.builtin(args[0], args[1:], input)
+	})
+	if !ok {
+		return
+	}
+	archive.Comment = []byte(comment)
+	if err := os.WriteFile(fname, txtar.Format(archive), 0644); err != nil {
+		tt.Fatalf("efft.WriteScript: %v", err)
+	}
+	tt.Logf("efft.ExpectationsUpdatedSuccessfully file=%s", fname)
+}
+
+// splitArgs splits the command line at the spaces but keeps the single quoted parts together.
+func splitArgs(line string) []string {
+	var args []string
+	var arg strings.Builder
+	inArg, quoted := false, false
+	for _, r := range line {
+		switch {
+		case r == '\'':
+			inArg, quoted = true, !quoted
+		case r == ' ' && !quoted:
+			if inArg {
+				args = append(args, arg.String())
+			}
+			arg.Reset()
+			inArg = false
+		default:
+			inArg = true
+			arg.WriteRune(r)
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+func (s *Script) builtin(name string, args []string, input string) string {
+	switch name {
+	case "exec":
+		if len(args) == 0 {
+			return "efft.BadArgs usage=`exec prog args...`"
+		}
+		return s.exec(args, input)
+	case "stdout":
+		return s.stdout
+	case "cd":
+		if len(args) != 1 {
+			return "efft.BadArgs usage=`cd dir`"
+		}
+		dir := s.Path(args[0])
+		if fi, err := os.Stat(dir); err != nil {
+			return "efft.ChangeDir: " + s.scrub(err.Error())
+		} else if !fi.IsDir() {
+			return "efft.ChangeDir: not a directory: " + args[0]
+		}
+		s.Dir = dir
+		return ""
+	case "env":
+		if len(args) == 0 {
+			return strings.Join(slices.Sorted(slices.Values(s.Env)), "\n")
+		}
+		var lines []string
+		for _, arg := range args {
+			if strings.Contains(arg, "=") {
+				s.Env = append(s.Env, arg)
+			} else {
+				lines = append(lines, arg+"="+s.Getenv(arg))
+			}
+		}
+		return strings.Join(lines, "\n")
+	case "cp":
+		if len(args) != 2 {
+			return "efft.BadArgs usage=`cp src dst`"
+		}
+		data, err := os.ReadFile(s.Path(args[0]))
+		if err == nil {
+			err = os.WriteFile(s.Path(args[1]), data, 0644)
+		}
+		if err != nil {
+			return "efft.CopyFile: " + s.scrub(err.Error())
+		}
+		return ""
+	case "cmp":
+		if len(args) != 2 {
+			return "efft.BadArgs usage=`cmp a b`"
+		}
+		a, err := os.ReadFile(s.Path(args[0]))
+		if err != nil {
+			return "efft.ReadFile: " + s.scrub(err.Error())
+		}
+		b, err := os.ReadFile(s.Path(args[1]))
+		if err != nil {
+			return "efft.ReadFile: " + s.scrub(err.Error())
+		}
+		return Diff(string(a), string(b))
+	}
+	return fmt.Sprintf("efft.UnknownCommand name=%q", name)
+}
+
+func (s *Script) exec(args []string, input string) string {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir, cmd.Env, cmd.Stdin = s.Dir, s.Env, strings.NewReader(input)
+	if !strings.Contains(args[0], string(filepath.Separator)) {
+		// Look up the program in the script's PATH rather than the test's.
+		path, err := lookPath(args[0], s.Getenv("PATH"))
+		cmd.Path, cmd.Err = path, err
+	}
+	r, err := runResult(cmd)
+	if err != nil {
+		return "efft.Exec: " + s.scrub(err.Error())
+	}
+	r.Stdout, r.Stderr = s.scrub(r.Stdout), s.scrub(r.Stderr)
+	s.stdout = r.Stdout
+	return r.String()
+}
+
+// lookPath finds the executable prog in the directories of path.
+func lookPath(prog, path string) (string, error) {
+	for _, dir := range filepath.SplitList(path) {
+		p := filepath.Join(dir, prog)
+		if fi, err := os.Stat(p); err == nil && fi.Mode().IsRegular() && fi.Mode()&0111 != 0 {
+			return p, nil
+		}
+	}
+	return "", &exec.Error{Name: prog, Err: exec.ErrNotFound}
+}
+
+func (s *Script) scrub(str string) string {
+	return strings.ReplaceAll(str, s.Work, "$WORK")
+}
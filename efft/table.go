@@ -0,0 +1,261 @@
+package efft
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SortRows sorts the rows of the stringified tables, e.g. for the results of SQL queries without ORDER BY.
+var SortRows bool
+
+// TableWidth enables the table layout in Stringify for the slices of flat structs or maps if it's positive.
+// Such slices are rendered as tables if no line of the table is wider than TableWidth.
+var TableWidth int
+
+// Table makes v stringify as a table if it's a slice of flat structs or maps.
+// A struct is flat if its exported fields are scalars, a map is flat if it has string keys and scalar values.
+// The columns of the structs are their fields, the columns of the maps are the union of their keys in sorted order.
+// Other values stringify as usual.
+// Use it as `efft.Effect(efft.Table(rows))`.
+func Table(v any) fmt.Stringer {
+	return tableValue{v}
+}
+
+type tableValue struct {
+	v any
+}
+
+func (tv tableValue) String() string {
+	if tbl, ok := newTable(tv.v); ok {
+		return tbl.String()
+	}
+	return stringify1(tv.v)
+}
+
+// autoTable returns the table rendering of v if it's enabled by TableWidth and v fits into it.
+func autoTable(v any) (string, bool) {
+	if TableWidth <= 0 {
+		return "", false
+	}
+	tbl, ok := newTable(v)
+	if !ok || len(tbl.rows) == 0 {
+		return "", false
+	}
+	s := tbl.String()
+	for _, line := range strings.Split(s, "\n") {
+		if utf8.RuneCountInString(line) > TableWidth {
+			return "", false
+		}
+	}
+	return s, true
+}
+
+// newTable converts a slice of flat structs or maps into a table.
+func newTable(v any) (*table, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	elemType := rv.Type().Elem()
+	if elemType.Kind() == reflect.Pointer {
+		elemType = elemType.Elem()
+	}
+	elems := make([]reflect.Value, rv.Len())
+	for i := range elems {
+		elems[i] = reflect.Indirect(rv.Index(i))
+		if !elems[i].IsValid() {
+			return nil, false
+		}
+	}
+
+	tbl := &table{}
+	switch {
+	case elemType.Kind() == reflect.Struct:
+		var fields []int
+		for i := range elemType.NumField() {
+			f := elemType.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if !isScalar(f.Type) {
+				return nil, false
+			}
+			fields = append(fields, i)
+			tbl.names = append(tbl.names, cmp.Or(name, f.Name))
+			tbl.types = append(tbl.types, f.Type.String())
+		}
+		if len(fields) == 0 {
+			return nil, false
+		}
+		for _, elem := range elems {
+			row := make([]string, len(fields))
+			for i, field := range fields {
+				row[i] = scalarValue(elem.Field(field))
+			}
+			tbl.rows = append(tbl.rows, row)
+		}
+	case elemType.Kind() == reflect.Map && elemType.Key().Kind() == reflect.String && isScalar(elemType.Elem()):
+		keys := map[string]bool{}
+		for _, elem := range elems {
+			for _, key := range elem.MapKeys() {
+				keys[key.String()] = true
+			}
+		}
+		if len(keys) == 0 {
+			return nil, false
+		}
+		tbl.names = slices.Sorted(maps.Keys(keys))
+		for range tbl.names {
+			tbl.types = append(tbl.types, elemType.Elem().String())
+		}
+		for _, elem := range elems {
+			row := make([]string, len(tbl.names))
+			for i, name := range tbl.names {
+				if value := elem.MapIndex(reflect.ValueOf(name).Convert(elemType.Key())); value.IsValid() {
+					row[i] = scalarValue(value)
+				}
+			}
+			tbl.rows = append(tbl.rows, row)
+		}
+	default:
+		return nil, false
+	}
+	return tbl, true
+}
+
+var stringerType = reflect.TypeFor[fmt.Stringer]()
+
+// isScalar reports whether the values of typ fit into a table cell.
+func isScalar(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Implements(stringerType) || reflect.PointerTo(typ).Implements(stringerType) {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func scalarValue(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "nil"
+		}
+		v = v.Elem()
+	}
+	if v.CanInterface() {
+		return sqlValue(v.Interface())
+	}
+	return cellEscaper.Replace(fmt.Sprint(v))
+}
+
+// table is a table to stringify: a header row with the column names, a row with their types and the data rows.
+type table struct {
+	names, types []string
+	rows         [][]string
+}
+
+// String renders the table with aligned columns.
+func (tbl *table) String() string {
+	rows := tbl.rows
+	if SortRows {
+		rows = slices.Clone(rows)
+		slices.SortStableFunc(rows, slices.Compare)
+	}
+	widths := make([]int, len(tbl.names))
+	for _, row := range append([][]string{tbl.names, tbl.types}, rows...) {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	var lines []string
+	formatRow := func(row []string) string {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = cell
+			if i < len(row)-1 {
+				cells[i] += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+			}
+		}
+		return strings.TrimRight(strings.Join(cells, " | "), " ")
+	}
+	lines = append(lines, formatRow(tbl.names), formatRow(tbl.types))
+	separator := make([]string, len(widths))
+	for i, w := range widths {
+		separator[i] = strings.Repeat("-", w)
+	}
+	lines = append(lines, strings.Join(separator, "-+-"))
+	for _, row := range rows {
+		lines = append(lines, formatRow(row))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stringifyRows renders the remaining rows as a table and closes them.
+func stringifyRows(rows *sql.Rows) string {
+	defer rows.Close()
+	names, err := rows.Columns()
+	if err != nil {
+		return "efft.ReadColumns: " + err.Error()
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return "efft.ReadColumns: " + err.Error()
+	}
+	tbl := &table{names: names}
+	for _, ct := range columnTypes {
+		typ := ct.DatabaseTypeName()
+		if typ == "" && ct.ScanType() != nil {
+			typ = ct.ScanType().String()
+		}
+		tbl.types = append(tbl.types, typ)
+	}
+	values := make([]any, len(names))
+	ptrs := make([]any, len(names))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return "efft.ScanRow: " + err.Error()
+		}
+		row := make([]string, len(values))
+		for i, v := range values {
+			row[i] = sqlValue(v)
+		}
+		tbl.rows = append(tbl.rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return "efft.ReadRows: " + err.Error()
+	}
+	return tbl.String()
+}
+
+// sqlValue renders a scanned value in a single line.
+func sqlValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return cellEscaper.Replace(string(v))
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return cellEscaper.Replace(fmt.Sprint(v))
+}
+
+var cellEscaper = strings.NewReplacer("\n", `\n`, "\r", `\r`, "\t", `\t`)
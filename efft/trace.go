@@ -0,0 +1,136 @@
+package efft
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Tracer records a sequence of events.
+// Pass its Log or Logf to the code under test and check the events with `efft.Effect(tr)`.
+// It's safe for concurrent use.
+// The zero value is ready to use.
+type Tracer struct {
+	// Lanes groups the events by the goroutine that logged them.
+	// Each goroutine's events keep their order but the lanes are sorted by their content
+	// so the trace is deterministic even if the goroutines interleave differently.
+	// Set it before the first event.
+	Lanes bool
+
+	mu     sync.Mutex
+	events []traceEvent
+}
+
+type traceEvent struct {
+	goroutine string
+	msg       string
+}
+
+// Trace returns a new Tracer.
+func Trace() *Tracer {
+	return &Tracer{}
+}
+
+// Log records an event formatted like fmt.Sprint.
+func (tr *Tracer) Log(args ...any) {
+	tr.add(fmt.Sprint(args...))
+}
+
+// Logf records an event formatted like fmt.Sprintf.
+func (tr *Tracer) Logf(format string, args ...any) {
+	tr.add(fmt.Sprintf(format, args...))
+}
+
+// LogCall records a method call in the `Method(args) = results` format.
+// The efftgen generated fakes use it.
+// The strings are quoted, the errors and fmt.Stringers are formatted with their methods and the rest is compact JSON.
+func (tr *Tracer) LogCall(method string, args, results []any) {
+	msg := method + "(" + traceValues(args) + ")"
+	if len(results) > 0 {
+		msg += " = " + traceValues(results)
+	}
+	tr.add(msg)
+}
+
+func traceValues(values []any) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = traceValue(v)
+	}
+	return strings.Join(s, ", ")
+}
+
+func traceValue(v any) string {
+	if rv := reflect.ValueOf(v); !rv.IsValid() || (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Func) && rv.IsNil() {
+		return "nil"
+	}
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	js, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(js)
+}
+
+func (tr *Tracer) add(msg string) {
+	var goroutine string
+	if tr.Lanes {
+		goroutine = goroutineID()
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.events = append(tr.events, traceEvent{goroutine, msg})
+}
+
+// goroutineID returns the current goroutine's ID from its stack trace's "goroutine 123 [running]:" header.
+func goroutineID() string {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf, _ = bytes.CutPrefix(buf, []byte("goroutine "))
+	id, _, _ := bytes.Cut(buf, []byte(" "))
+	return string(id)
+}
+
+// String returns the recorded events, one per line.
+// In the Lanes mode each lane starts with a "lane N:" line and its events are indented.
+func (tr *Tracer) String() string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if !tr.Lanes {
+		msgs := make([]string, len(tr.events))
+		for i, e := range tr.events {
+			msgs[i] = e.msg
+		}
+		return strings.Join(msgs, "\n")
+	}
+	var order []string
+	lanes := map[string][]string{}
+	for _, e := range tr.events {
+		if _, found := lanes[e.goroutine]; !found {
+			order = append(order, e.goroutine)
+		}
+		lanes[e.goroutine] = append(lanes[e.goroutine], "  "+strings.ReplaceAll(e.msg, "\n", "\n  "))
+	}
+	contents := make([]string, len(order))
+	for i, goroutine := range order {
+		contents[i] = strings.Join(lanes[goroutine], "\n")
+	}
+	slices.Sort(contents)
+	for i, content := range contents {
+		contents[i] = fmt.Sprintf("lane %d:\n%s", i+1, content)
+	}
+	return strings.Join(contents, "\n")
+}
@@ -7,6 +7,10 @@ import (
 	"github.com/ypsu/efftesting/efft"
 )
 
+func TestMain(m *testing.M) {
+	efft.Main(m)
+}
+
 func TestSplit(t *testing.T) {
 	efft.Init(t)
 
@@ -1,3 +1,14 @@
 module github.com/ypsu/efftesting
 
-go 1.21
+go 1.23.0
+
+require (
+	golang.org/x/net v0.43.0
+	golang.org/x/text v0.28.0
+	golang.org/x/tools v0.36.0
+)
+
+require (
+	golang.org/x/mod v0.27.0 // indirect
+	golang.org/x/sync v0.16.0 // indirect
+)